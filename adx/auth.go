@@ -0,0 +1,359 @@
+package adx
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// tokenRefreshWithin is how long before expiry a token obtained outside of adal is refreshed.
+const tokenRefreshWithin = 5 * time.Minute
+
+// opaqueTokenLifetime is assumed for tokens whose expiry cannot be read from the token itself.
+const opaqueTokenLifetime = 15 * time.Minute
+
+// staticToken is an adal.OAuthTokenProvider for a token supplied as-is, which is never refreshed.
+type staticToken string
+
+func (t staticToken) OAuthToken() string {
+	return string(t)
+}
+
+// refreshingToken implements adal.OAuthTokenProvider and adal.RefresherWithContext for tokens which are
+// obtained outside of adal, e.g. from the Azure CLI, so they can be used with autorest.NewBearerAuthorizer.
+type refreshingToken struct {
+	fetch func(ctx context.Context) (string, time.Time, error)
+
+	mu        sync.RWMutex
+	token     string
+	expiresOn time.Time
+}
+
+func newRefreshingToken(fetch func(ctx context.Context) (string, time.Time, error)) *refreshingToken {
+	return &refreshingToken{fetch: fetch}
+}
+
+func (t *refreshingToken) OAuthToken() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.token
+}
+
+func (t *refreshingToken) EnsureFreshWithContext(ctx context.Context) error {
+	t.mu.RLock()
+	fresh := t.token != "" && time.Now().Add(tokenRefreshWithin).Before(t.expiresOn)
+	t.mu.RUnlock()
+
+	if fresh {
+		return nil
+	}
+	return t.RefreshWithContext(ctx)
+}
+
+func (t *refreshingToken) RefreshWithContext(ctx context.Context) error {
+	token, expiresOn, err := t.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+	t.expiresOn = expiresOn
+	return nil
+}
+
+func (t *refreshingToken) RefreshExchangeWithContext(ctx context.Context, _ string) error {
+	return t.RefreshWithContext(ctx)
+}
+
+func (c *Config) msiAuthorizer() (autorest.Authorizer, error) {
+	endpoint := c.MSIEndpoint
+	if endpoint == "" {
+		var err error
+		if endpoint, err = adal.GetMSIEndpoint(); err != nil {
+			return nil, fmt.Errorf("error discovering managed identity endpoint: %+v", err)
+		}
+	}
+
+	var spt *adal.ServicePrincipalToken
+	var err error
+	if c.MSIClientID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(endpoint, c.resource(), c.MSIClientID)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(endpoint, c.resource())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error configuring managed identity authentication: %+v", err)
+	}
+
+	return autorest.NewBearerAuthorizer(spt), nil
+}
+
+// cliToken is the output of `az account get-access-token`. Newer versions of the CLI also report
+// expires_on as a unix timestamp, which is preferred over the local time in expiresOn.
+type cliToken struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresOn   string `json:"expiresOn"`
+	ExpiresOnTS int64  `json:"expires_on"`
+}
+
+func (c *Config) cliAuthorizer() (autorest.Authorizer, error) {
+	path := c.CLIPath
+	if path == "" {
+		path = "az"
+	}
+	resource := c.resource()
+
+	return autorest.NewBearerAuthorizer(newRefreshingToken(func(ctx context.Context) (string, time.Time, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, path, "account", "get-access-token", "--resource", resource, "--output", "json")
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return "", time.Time{}, fmt.Errorf("error obtaining token from Azure CLI: %+v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		var token cliToken
+		if err := json.Unmarshal(stdout.Bytes(), &token); err != nil {
+			return "", time.Time{}, fmt.Errorf("error parsing Azure CLI token: %+v", err)
+		}
+		if token.AccessToken == "" {
+			return "", time.Time{}, fmt.Errorf("error parsing Azure CLI token: no accessToken returned")
+		}
+
+		if token.ExpiresOnTS != 0 {
+			return token.AccessToken, time.Unix(token.ExpiresOnTS, 0), nil
+		}
+
+		expiresOn, err := time.ParseInLocation("2006-01-02 15:04:05.999999", token.ExpiresOn, time.Local)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("error parsing Azure CLI token expiry %q: %+v", token.ExpiresOn, err)
+		}
+
+		return token.AccessToken, expiresOn, nil
+	})), nil
+}
+
+// tokenCommandAuthorizer runs token_command whenever a token is needed and the previous one is about to
+// expire. The command must print the bearer token, and nothing else, to stdout.
+func (c *Config) tokenCommandAuthorizer() (autorest.Authorizer, error) {
+	command := c.TokenCommand
+
+	return autorest.NewBearerAuthorizer(newRefreshingToken(func(ctx context.Context) (string, time.Time, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return "", time.Time{}, fmt.Errorf("error running token_command: %+v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		token := strings.TrimSpace(stdout.String())
+		if token == "" {
+			return "", time.Time{}, fmt.Errorf("error running token_command: no token was printed")
+		}
+
+		return token, tokenExpiry(token), nil
+	})), nil
+}
+
+// tokenExpiry reads the `exp` claim of a JWT without validating it. Tokens which aren't JWTs are assumed
+// to be valid for opaqueTokenLifetime.
+func tokenExpiry(token string) time.Time {
+	fallback := time.Now().Add(opaqueTokenLifetime)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fallback
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
+
+func (c *Config) certificateAuthorizer() (autorest.Authorizer, error) {
+	if c.ClientID == "" || c.TenantID == "" {
+		return nil, fmt.Errorf("client_id and tenant_id must be set when using client certificate authentication")
+	}
+	if c.ClientCertificatePath != "" && c.ClientCertificate != "" {
+		return nil, fmt.Errorf("only one of client_certificate_path and client_certificate can be set")
+	}
+
+	var certData []byte
+	var err error
+	if c.ClientCertificatePath != "" {
+		if certData, err = ioutil.ReadFile(c.ClientCertificatePath); err != nil {
+			return nil, fmt.Errorf("error reading client certificate %q: %+v", c.ClientCertificatePath, err)
+		}
+	} else {
+		if certData, err = base64.StdEncoding.DecodeString(c.ClientCertificate); err != nil {
+			return nil, fmt.Errorf("error decoding client_certificate: expected base64 encoded PKCS#12 content: %+v", err)
+		}
+	}
+
+	privateKey, certificate, err := pkcs12.Decode(certData, c.ClientCertificatePassword)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding PKCS#12 client certificate: %+v", err)
+	}
+	rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("error decoding PKCS#12 client certificate: the certificate must contain an RSA private key")
+	}
+
+	cloud, err := c.cloud()
+	if err != nil {
+		return nil, err
+	}
+
+	oauthConfig, err := adal.NewOAuthConfig(cloud.AuthorityHost, c.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring client certificate authentication: %+v", err)
+	}
+
+	spt, err := adal.NewServicePrincipalTokenFromCertificate(*oauthConfig, c.ClientID, certificate, rsaPrivateKey, c.resource())
+	if err != nil {
+		return nil, fmt.Errorf("error configuring client certificate authentication: %+v", err)
+	}
+	spt.SetSender(c.sender())
+
+	return autorest.NewBearerAuthorizer(spt), nil
+}
+
+// oidcAuthorizer exchanges a federated token, read from a file (e.g. Kubernetes workload identity) or requested
+// from a URL (e.g. GitHub Actions), for an AAD token for the cluster. The federated token is re-read on every
+// refresh since it is rotated by its issuer.
+func (c *Config) oidcAuthorizer() (autorest.Authorizer, error) {
+	if c.ClientID == "" || c.TenantID == "" {
+		return nil, fmt.Errorf("client_id and tenant_id must be set when using OIDC authentication")
+	}
+	if c.OIDCTokenFilePath == "" && c.OIDCRequestURL == "" {
+		return nil, fmt.Errorf("one of oidc_token_file_path or oidc_request_url must be set when using OIDC authentication")
+	}
+
+	cloud, err := c.cloud()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenEndpoint := c.OIDCTokenEndpoint
+	if tokenEndpoint == "" {
+		tokenEndpoint = fmt.Sprintf("%s%s/oauth2/v2.0/token", cloud.AuthorityHost, c.TenantID)
+	}
+	scope := strings.TrimSuffix(c.resource(), "/") + "/.default"
+
+	return autorest.NewBearerAuthorizer(newRefreshingToken(func(ctx context.Context) (string, time.Time, error) {
+		assertion, err := c.oidcAssertion(ctx, cloud.FederatedTokenAudience)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", c.ClientID)
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", assertion)
+		form.Set("scope", scope)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("error building OIDC token exchange request: %+v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var token struct {
+			AccessToken string      `json:"access_token"`
+			ExpiresIn   json.Number `json:"expires_in"`
+		}
+		if err := c.doJSONRequest(req, &token); err != nil {
+			return "", time.Time{}, fmt.Errorf("error exchanging OIDC token: %+v", err)
+		}
+
+		expiresIn, err := token.ExpiresIn.Int64()
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("error parsing OIDC token expiry %q: %+v", token.ExpiresIn, err)
+		}
+
+		return token.AccessToken, time.Now().Add(time.Duration(expiresIn) * time.Second), nil
+	})), nil
+}
+
+func (c *Config) oidcAssertion(ctx context.Context, audience string) (string, error) {
+	if c.OIDCTokenFilePath != "" {
+		b, err := ioutil.ReadFile(c.OIDCTokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("error reading OIDC token file %q: %+v", c.OIDCTokenFilePath, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	u, err := url.Parse(c.OIDCRequestURL)
+	if err != nil {
+		return "", fmt.Errorf("error parsing oidc_request_url: %+v", err)
+	}
+	query := u.Query()
+	query.Set("audience", audience)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error building OIDC token request: %+v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.OIDCRequestToken)
+
+	var token struct {
+		Value string `json:"value"`
+	}
+	if err := c.doJSONRequest(req, &token); err != nil {
+		return "", fmt.Errorf("error requesting OIDC token: %+v", err)
+	}
+
+	return token.Value, nil
+}
+
+func (c *Config) doJSONRequest(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.sender().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode, req.URL.Host, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, v)
+}
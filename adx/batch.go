@@ -0,0 +1,310 @@
+package adx
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+// Execute runs a command which changes metadata and discards its result. Async commands wait for the
+// operation they start, see MgmtAsync. With batch_commands, other commands are run as part of a database
+// script together with the other commands for the database which arrive within batch_window.
+func (c *kustoClient) Execute(ctx context.Context, db string, query kusto.Stmt) error {
+	// Async commands can't be part of a database script
+	if isAsyncCommand(query.String()) {
+		return c.MgmtAsync(ctx, db, query)
+	}
+
+	// Cluster level commands can't be part of a database script either, and commands with their own client
+	// request properties are sent on their own
+	if c.batcher != nil && db != "" && clientRequestProperties(ctx, nil) == nil {
+		return c.batcher.execute(ctx, db, query.String())
+	}
+
+	resp, err := c.Mgmt(ctx, db, query)
+	if err != nil {
+		return err
+	}
+	resp.Stop()
+
+	return nil
+}
+
+func isAsyncCommand(command string) bool {
+	fields := strings.Fields(strings.ToLower(command))
+	return len(fields) > 1 && fields[1] == "async"
+}
+
+// commandBatcher collects the commands for each database of a cluster and runs them together with
+// `.execute database script`.
+type commandBatcher struct {
+	client *kustoClient
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*commandBatch
+}
+
+type commandBatch struct {
+	db       string
+	commands []*batchedCommand
+	flushed  bool
+}
+
+type batchedCommand struct {
+	command  string
+	deadline time.Time
+	result   chan error
+}
+
+func newCommandBatcher(client *kustoClient, window time.Duration) *commandBatcher {
+	return &commandBatcher{
+		client:  client,
+		window:  window,
+		pending: map[string]*commandBatch{},
+	}
+}
+
+// execute adds command to the pending batch for db, starting a new batch if there is none, and waits for
+// the command's result.
+func (b *commandBatcher) execute(ctx context.Context, db string, command string) error {
+	cmd := &batchedCommand{
+		command: command,
+		result:  make(chan error, 1),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		cmd.deadline = deadline
+	}
+
+	key := strings.ToLower(db)
+
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &commandBatch{db: db}
+		b.pending[key] = batch
+		time.AfterFunc(b.window, func() { b.flush(key, batch) })
+	}
+	batch.commands = append(batch.commands, cmd)
+	b.mu.Unlock()
+
+	select {
+	case err := <-cmd.result:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Withdraw the command if the batch hasn't been sent yet. Once sent, the command runs on the cluster
+	// regardless, so wait for its result rather than report it as failed; the script itself is bounded by the
+	// latest deadline of its commands and cancelled when Terraform is interrupted.
+	b.mu.Lock()
+	if !batch.flushed {
+		for i, c := range batch.commands {
+			if c == cmd {
+				batch.commands = append(batch.commands[:i], batch.commands[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+	b.mu.Unlock()
+
+	return <-cmd.result
+}
+
+func (b *commandBatcher) flush(key string, batch *commandBatch) {
+	b.mu.Lock()
+	delete(b.pending, key)
+	batch.flushed = true
+	commands := batch.commands
+	b.mu.Unlock()
+
+	if len(commands) == 0 {
+		return
+	}
+
+	// The batch runs until the latest deadline of its commands, since each command's own context only
+	// bounds how long its resource waits for the result
+	ctx := context.Background()
+	var deadline time.Time
+	for _, cmd := range commands {
+		if cmd.deadline.IsZero() {
+			deadline = time.Time{}
+			break
+		}
+		if cmd.deadline.After(deadline) {
+			deadline = cmd.deadline
+		}
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+
+	if len(commands) == 1 {
+		resp, err := b.client.Mgmt(ctx, batch.db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(commands[0].command))
+		if err == nil {
+			resp.Stop()
+		}
+		commands[0].result <- err
+		return
+	}
+
+	lines := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		lines = append(lines, cmd.command)
+	}
+	script := ".execute database script with (ContinueOnErrors=false) <|\n" + strings.Join(lines, "\n")
+
+	log.Printf("[DEBUG] Running %d commands as a database script on %s (Database %q)", len(commands), b.client.endpoint, batch.db)
+
+	// The script isn't transactional: the commands before a failed one stay applied. Each command is
+	// therefore given its own result, so that its resource is saved or not accordingly.
+	results, err := b.runScript(ctx, batch.db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(script))
+	if err != nil && len(results) == 0 && isScriptRejected(err) {
+		// The service validates the whole script before running any of it, so none of the commands ran.
+		// Run them one by one to attribute the error to the commands which cause it.
+		log.Printf("[DEBUG] Database script on %s (Database %q) was rejected, running its commands one by one: %s", b.client.endpoint, batch.db, redactCommand(err.Error()))
+		for _, cmd := range commands {
+			resp, err := b.client.Mgmt(ctx, batch.db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(cmd.command))
+			if err == nil {
+				resp.Stop()
+			}
+			cmd.result <- err
+		}
+		return
+	}
+
+	for i, result := range matchScriptResults(commands, results) {
+		switch {
+		case result != nil:
+			commands[i].result <- b.commandError(batch.db, commands[i].command, result.err())
+		case err != nil:
+			commands[i].result <- b.scriptError(batch.db, commands[i].command, err)
+		default:
+			commands[i].result <- b.commandError(batch.db, commands[i].command, fmt.Errorf("database script returned no result for the command"))
+		}
+	}
+}
+
+// isScriptRejected reports whether err is the service refusing a database script as invalid, as opposed to
+// the script failing while it runs.
+func isScriptRejected(err error) bool {
+	serviceErr := parseKustoServiceError(err)
+	return serviceErr != nil && strings.Contains(serviceErr.Code, "BadRequest")
+}
+
+// matchScriptResults returns the result of each command of a database script, or nil for the commands
+// without one. The rows are matched by their CommandText and, for rows without it, by their position.
+func matchScriptResults(commands []*batchedCommand, results []scriptResult) []*scriptResult {
+	matched := make([]*scriptResult, len(commands))
+	used := make([]bool, len(results))
+
+	for i, cmd := range commands {
+		command := strings.TrimSpace(cmd.command)
+		if i < len(results) && !used[i] && (results[i].CommandText == "" || strings.TrimSpace(results[i].CommandText) == command) {
+			matched[i], used[i] = &results[i], true
+			continue
+		}
+		for j := range results {
+			if !used[j] && strings.TrimSpace(results[j].CommandText) == command {
+				matched[i], used[j] = &results[j], true
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// commandError attributes the failure of a command in a database script to the command itself, so that it is
+// reported like a command run on its own.
+func (b *commandBatcher) commandError(db string, command string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &commandError{
+		Endpoint: b.client.endpoint,
+		Database: db,
+		Command:  redactCommand(command),
+		Err:      err,
+	}
+}
+
+// scriptError reports the failure of a whole database script on one of its commands whose result is unknown,
+// keeping the client request ID of the script.
+func (b *commandBatcher) scriptError(db string, command string, err error) error {
+	var clientRequestID string
+	var cmdErr *commandError
+	if stderrors.As(err, &cmdErr) {
+		clientRequestID, err = cmdErr.ClientRequestID, cmdErr.Err
+	}
+	return &commandError{
+		Endpoint:        b.client.endpoint,
+		Database:        db,
+		Command:         redactCommand(command),
+		ClientRequestID: clientRequestID,
+		Err:             fmt.Errorf("database script failed before reporting the result of the command, which may have been applied: %w", err),
+	}
+}
+
+// scriptResult is a row of the output of `.execute database script`, one per command.
+type scriptResult struct {
+	OperationId string
+	CommandType string
+	CommandText string
+	Result      string
+	Reason      string
+}
+
+func (r scriptResult) err() error {
+	switch r.Result {
+	case "Completed":
+		return nil
+	case "Skipped":
+		return fmt.Errorf("command was skipped as an earlier command in the database script failed")
+	default:
+		return fmt.Errorf("%s: %s", r.Result, r.Reason)
+	}
+}
+
+func (b *commandBatcher) runScript(ctx context.Context, db string, query kusto.Stmt) ([]scriptResult, error) {
+	resp, err := b.client.Mgmt(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Stop()
+
+	var results []scriptResult
+	err = resp.Do(
+		func(row *table.Row) error {
+			columns := map[string]string{}
+			for i, column := range row.ColumnTypes {
+				columns[column.Name] = row.Values[i].String()
+			}
+			results = append(results, scriptResult{
+				OperationId: columns["OperationId"],
+				CommandType: columns["CommandType"],
+				CommandText: columns["CommandText"],
+				Result:      columns["Result"],
+				Reason:      columns["Reason"],
+			})
+			return nil
+		},
+	)
+
+	// The rows read before an error still report the results of their commands
+	return results, err
+}
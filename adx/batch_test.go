@@ -0,0 +1,308 @@
+package adx
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+// testScriptServer answers database scripts with the results for their commands, failing commands which
+// contain "fail" and skipping the commands after them. It records the commands it receives.
+func testScriptServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var commands []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CSL string `json:"csl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %+v", err)
+		}
+		mu.Lock()
+		commands = append(commands, body.CSL)
+		mu.Unlock()
+
+		lines := strings.Split(body.CSL, "\n")
+		if !strings.HasPrefix(lines[0], ".execute database script") {
+			fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+			return
+		}
+
+		var rows []string
+		failed := false
+		for _, command := range lines[1:] {
+			result, reason := "Completed", ""
+			switch {
+			case failed:
+				result = "Skipped"
+			case strings.Contains(command, "fail"):
+				result, reason, failed = "Failed", "Semantic error", true
+			}
+			rows = append(rows, fmt.Sprintf(`["00000000-0000-0000-0000-000000000001","TableCreate",%q,%q,%q]`, command, result, reason))
+		}
+		columns := `{"ColumnName":"OperationId","DataType":"String"},{"ColumnName":"CommandType","DataType":"String"},{"ColumnName":"CommandText","DataType":"String"},{"ColumnName":"Result","DataType":"String"},{"ColumnName":"Reason","DataType":"String"}`
+		fmt.Fprintf(w, `{"Tables":[{"TableName":"Table_0","Columns":[%s],"Rows":[%s]}]}`, columns, strings.Join(rows, ","))
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), commands...)
+	}
+}
+
+func testStmt(command string) kusto.Stmt {
+	return kusto.NewStmt("", kusto.UnsafeStmt(unsafe.Stmt{Add: true})).UnsafeAdd(command)
+}
+
+// testBatchConfig batches commands with a window which doesn't elapse during a test, so that tests flush the
+// batches themselves.
+func testBatchConfig(endpoint string) *Config {
+	return &Config{Endpoint: endpoint, BatchCommands: true, BatchWindow: time.Hour}
+}
+
+// testPendingBatch waits until the pending batch for db holds count commands, and returns it.
+func testPendingBatch(t *testing.T, b *commandBatcher, db string, count int) *commandBatch {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		batch := b.pending[strings.ToLower(db)]
+		pending := 0
+		if batch != nil {
+			pending = len(batch.commands)
+		}
+		b.mu.Unlock()
+
+		if pending == count {
+			return batch
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d pending commands for %q, got %d", count, db, pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testExecuteAll runs commands on db through the batcher in order: each command is pending before the next one
+// is sent, and the batch is flushed once all of them are.
+func testExecuteAll(t *testing.T, client *kustoClient, db string, commands ...string) []error {
+	errs := make([]error, len(commands))
+
+	var wg sync.WaitGroup
+	var batch *commandBatch
+	for i, command := range commands {
+		wg.Add(1)
+		go func(i int, command string) {
+			defer wg.Done()
+			errs[i] = client.Execute(context.Background(), db, testStmt(command))
+		}(i, command)
+		batch = testPendingBatch(t, client.batcher, db, i+1)
+	}
+	client.batcher.flush(strings.ToLower(db), batch)
+	wg.Wait()
+
+	return errs
+}
+
+func TestKustoClient_ExecuteBatch(t *testing.T) {
+	server, commands := testScriptServer(t)
+	defer server.Close()
+
+	client := testClient(t, testBatchConfig(server.URL), context.Background())
+
+	errs := testExecuteAll(t, client, "test-db", ".create table A (a:string)", ".create table B (b:string)", ".create table C (c:string)")
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("command %d: %s", i, err)
+		}
+	}
+
+	expected := ".execute database script with (ContinueOnErrors=false) <|\n.create table A (a:string)\n.create table B (b:string)\n.create table C (c:string)"
+	if got := commands(); len(got) != 1 || got[0] != expected {
+		t.Fatalf("expected a single database script, got: %q", got)
+	}
+}
+
+func TestKustoClient_ExecuteBatchFailure(t *testing.T) {
+	server, _ := testScriptServer(t)
+	defer server.Close()
+
+	client := testClient(t, testBatchConfig(server.URL), context.Background())
+
+	errs := testExecuteAll(t, client, "test-db", ".create table A (a:string)", ".create table fail (b:unknown)", ".create table C (c:string)")
+	if errs[0] != nil {
+		t.Fatalf("expected the first command to succeed, got: %s", errs[0])
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "Semantic error") {
+		t.Fatalf("expected the failing command to report its reason, got: %v", errs[1])
+	}
+	if errs[2] == nil || !strings.Contains(errs[2].Error(), "skipped") {
+		t.Fatalf("expected the command after the failure to be skipped, got: %v", errs[2])
+	}
+}
+
+func TestKustoClient_ExecuteBatchRejected(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CSL string `json:"csl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %+v", err)
+		}
+		mu.Lock()
+		commands = append(commands, body.CSL)
+		mu.Unlock()
+
+		if strings.Contains(body.CSL, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"code":"BadRequest_SyntaxError","message":"Request is invalid and cannot be executed."}}`)
+			return
+		}
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+	defer server.Close()
+
+	client := testClient(t, testBatchConfig(server.URL), context.Background())
+
+	errs := testExecuteAll(t, client, "test-db", ".create table A (a:string)", ".create table fail (b:unknown)", ".create table C (c:string)")
+	if errs[0] != nil || errs[2] != nil {
+		t.Fatalf("expected the valid commands to succeed, got: %v, %v", errs[0], errs[2])
+	}
+	var cmdErr *commandError
+	if !stderrors.As(errs[1], &cmdErr) || cmdErr.Command != ".create table fail (b:unknown)" {
+		t.Fatalf("expected the error to be attributed to the invalid command, got: %#v", errs[1])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(commands) != 4 {
+		t.Fatalf("expected the rejected script to be followed by each of its commands, got: %q", commands)
+	}
+}
+
+func TestKustoClient_ExecuteBatchUnknownOutcome(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error":{"code":"Internal_ServiceError","message":"Service is unavailable."}}`)
+	}))
+	defer server.Close()
+
+	client := testClient(t, testBatchConfig(server.URL), context.Background())
+
+	commands := []string{".create table A (a:string)", ".create table B (b:string)"}
+	errs := testExecuteAll(t, client, "test-db", commands...)
+	for i, err := range errs {
+		var cmdErr *commandError
+		if !stderrors.As(err, &cmdErr) || cmdErr.Command != commands[i] {
+			t.Fatalf("command %d: expected the error to be reported on the command, got: %#v", i, err)
+		}
+		if !strings.Contains(err.Error(), "may have been applied") {
+			t.Fatalf("command %d: expected the outcome to be reported as unknown, got: %s", i, err)
+		}
+	}
+}
+
+func TestKustoClient_ExecuteBatchWaitsAfterFlush(t *testing.T) {
+	server, _ := testScriptServer(t)
+	defer server.Close()
+	// Hold the script until the context of the first command is done
+	received, release := make(chan struct{}), make(chan struct{})
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+		handler.ServeHTTP(w, r)
+	})
+
+	client := testClient(t, testBatchConfig(server.URL), context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = client.Execute(ctx, "test-db", testStmt(".create table A (a:string)"))
+	}()
+	testPendingBatch(t, client.batcher, "test-db", 1)
+	go func() {
+		defer wg.Done()
+		errs[1] = client.Execute(context.Background(), "test-db", testStmt(".create table B (b:string)"))
+	}()
+	batch := testPendingBatch(t, client.batcher, "test-db", 2)
+	go client.batcher.flush("test-db", batch)
+
+	<-received
+	cancel()
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("command %d: expected the result of the command once it was sent, got: %s", i, err)
+		}
+	}
+}
+
+func TestMatchScriptResults(t *testing.T) {
+	commands := []*batchedCommand{{command: ".create table A (a:string)"}, {command: ".create table B (b:string)"}, {command: ".create table C (c:string)"}}
+	results := []scriptResult{
+		{CommandText: ".create table B (b:string)", Result: "Failed"},
+		{CommandText: ".create table A (a:string)", Result: "Completed"},
+	}
+
+	matched := matchScriptResults(commands, results)
+	if matched[0] == nil || matched[0].Result != "Completed" || matched[1] == nil || matched[1].Result != "Failed" || matched[2] != nil {
+		t.Fatalf("expected the results to be matched by command text, got: %+v", matched)
+	}
+}
+
+func TestKustoClient_ExecuteBatchSeparatesDatabases(t *testing.T) {
+	server, commands := testScriptServer(t)
+	defer server.Close()
+
+	client := testClient(t, testBatchConfig(server.URL), context.Background())
+
+	errs := append(
+		testExecuteAll(t, client, "db-1", ".create table A (a:string)"),
+		testExecuteAll(t, client, "db-2", ".create table B (b:string)")...,
+	)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("command %d: %s", i, err)
+		}
+	}
+
+	if got := commands(); len(got) != 2 || got[0] != ".create table A (a:string)" || got[1] != ".create table B (b:string)" {
+		t.Fatalf("expected single commands to be run as-is, got: %q", got)
+	}
+}
+
+func TestIsAsyncCommand(t *testing.T) {
+	cases := map[string]bool{
+		".create async materialized-view MV on table T { T }": true,
+		".create table async (a:string)":                      false,
+		".create table T (a:string)":                          false,
+	}
+
+	for command, expected := range cases {
+		if got := isAsyncCommand(command); got != expected {
+			t.Errorf("%q: expected %t, got %t", command, expected, got)
+		}
+	}
+}
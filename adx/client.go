@@ -0,0 +1,313 @@
+package adx
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/hashicorp/go-uuid"
+)
+
+// kustoClient wraps the kusto.Clients for a single cluster with the behaviour shared by all resources.
+// Resources call Mgmt on it exactly as they would on a kusto.Client.
+type kustoClient struct {
+	endpoint string
+	retry    retryPolicy
+	// stop is cancelled when Terraform is interrupted.
+	stop       context.Context
+	commandLog *commandLogger
+	// properties are the provider's client_request_properties.
+	properties map[string]string
+	// databaseLocks serializes metadata changes per database, and schemas caches the schema of each
+	// database. Both are shared by the clients of all clusters.
+	databaseLocks *keyedMutex
+	schemas       *schemaCache
+
+	// batcher collects metadata changes into database scripts if batch_commands is set.
+	batcher *commandBatcher
+
+	// connections is the pool of SDK clients for the cluster. The SDK runs one command per client at a
+	// time, so the size of the pool, max_concurrent_commands, bounds the commands running concurrently.
+	connections chan *kustoConnection
+}
+
+// kustoConnection is an SDK client together with the requestAuthorizer which prepares its requests.
+type kustoConnection struct {
+	*kusto.Client
+	requests *requestAuthorizer
+}
+
+// defaultMaxConcurrentCommands keeps the commands on a cluster serialized, as they were before
+// max_concurrent_commands, to protect small clusters.
+const defaultMaxConcurrentCommands = 1
+
+// clientRequestIDPrefix and applicationName identify the provider's requests in `.show commands` and the
+// cluster's diagnostics.
+const (
+	clientRequestIDPrefix = "terraform-provider-adx;"
+	applicationName       = "terraform-provider-adx"
+)
+
+// requestAuthorizer wraps the Authorizer handed to the SDK, which is the only hook into the requests the
+// SDK sends. It tags every request with a client request ID, the application name and the provider's user
+// agent, adds the client request properties of the current command, and records the client request ID of
+// the last request.
+type requestAuthorizer struct {
+	autorest.Authorizer
+	userAgent string
+
+	mu            sync.Mutex
+	properties    map[string]string
+	lastRequestID string
+}
+
+func (a *requestAuthorizer) WithAuthorization() autorest.PrepareDecorator {
+	authorize := a.Authorizer.WithAuthorization()
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			id, err := uuid.GenerateUUID()
+			if err != nil {
+				return r, fmt.Errorf("error generating client request ID: %+v", err)
+			}
+			requestID := clientRequestIDPrefix + id
+
+			r.Header.Set("x-ms-client-request-id", requestID)
+			r.Header.Set("x-ms-app", applicationName)
+			if a.userAgent != "" {
+				r.Header.Set("User-Agent", a.userAgent)
+			}
+
+			a.mu.Lock()
+			a.lastRequestID = requestID
+			properties := a.properties
+			a.mu.Unlock()
+
+			if err := setClientRequestProperties(r, properties); err != nil {
+				return r, err
+			}
+
+			return authorize(p).Prepare(r)
+		})
+	}
+}
+
+// SetProperties sets the client request properties of the requests prepared from now on.
+func (a *requestAuthorizer) SetProperties(properties map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.properties = properties
+}
+
+func (a *requestAuthorizer) LastRequestID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.lastRequestID
+}
+
+type retryPolicy struct {
+	MaxRetries int
+	BaseWait   time.Duration
+	MaxWait    time.Duration
+}
+
+const defaultRetryBaseWait = 1 * time.Second
+
+// maxCommandTimeout is the longest server timeout Kusto accepts. The SDK rejects contexts with a later
+// deadline, so the deadline of each attempt, and with it the server timeout, is capped at this.
+const maxCommandTimeout = 1 * time.Hour
+
+// backoff returns the wait before retry number attempt (starting at 0): exponential growth capped at
+// MaxWait, with jitter over the upper half of the interval so concurrent resources don't retry in lockstep.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	wait := p.MaxWait
+	if attempt < 32 {
+		if d := p.BaseWait << uint(attempt); d > 0 && d < p.MaxWait {
+			wait = d
+		}
+	}
+
+	half := int64(wait / 2)
+	if half <= 0 {
+		return wait
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Mgmt runs a management command, retrying throttling and transient failures according to the retry policy.
+// The SDK sets the command's server timeout from the deadline of ctx, which for resources is derived from
+// their configured timeouts.
+func (c *kustoClient) Mgmt(ctx context.Context, db string, query kusto.Stmt, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+	ctx = c.stoppable(ctx)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.mgmt(commandContext(ctx), db, query, options...)
+		if err == nil || attempt >= c.retry.MaxRetries || ctx.Err() != nil || !isRetryableError(err) {
+			return resp, err
+		}
+
+		wait := c.retry.backoff(attempt)
+		log.Printf("[DEBUG] Retrying management command on %s (Database %q) in %s after attempt %d failed: %s", c.endpoint, db, wait, attempt+1, redactCommand(err.Error()))
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// mgmt runs a single attempt of a command on a connection from the pool and records it in the command log.
+// Commands which change metadata wait for other changes to the same database to finish first, to avoid
+// concurrent metadata change conflicts.
+func (c *kustoClient) mgmt(ctx context.Context, db string, query kusto.Stmt, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+	if !isReadOnlyCommand(query.String()) {
+		key := databaseLockKey(c.endpoint, db)
+		unlock, ok := c.databaseLocks.TryLock(key)
+		if !ok {
+			log.Printf("[DEBUG] Waiting for other metadata changes to Database %q on %s to finish", db, c.endpoint)
+
+			var err error
+			if unlock, err = c.databaseLocks.Lock(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		defer unlock()
+
+		// Even a failed command may have changed metadata, e.g. part of a database script
+		defer c.schemas.invalidate(key)
+	}
+
+	conn, err := c.acquire(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { c.connections <- conn }()
+
+	conn.requests.SetProperties(clientRequestProperties(ctx, c.properties))
+
+	start := time.Now()
+	resp, err := conn.Mgmt(ctx, db, query, options...)
+	c.commandLog.record(c.endpoint, db, query.String(), start, conn.requests.LastRequestID(), err)
+	if err != nil {
+		return nil, &commandError{
+			Endpoint:        c.endpoint,
+			Database:        db,
+			Command:         redactCommand(query.String()),
+			ClientRequestID: conn.requests.LastRequestID(),
+			Err:             err,
+		}
+	}
+
+	return resp, nil
+}
+
+// acquire takes a connection from the pool, waiting for one to be returned if max_concurrent_commands
+// commands are already running on the cluster.
+func (c *kustoClient) acquire(ctx context.Context, db string) (*kustoConnection, error) {
+	select {
+	case conn := <-c.connections:
+		return conn, nil
+	default:
+	}
+
+	log.Printf("[DEBUG] Waiting for a free slot to run a management command on %s (Database %q): %d commands are already running", c.endpoint, db, cap(c.connections))
+
+	select {
+	case conn := <-c.connections:
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// stoppable returns a context which is also cancelled when Terraform is interrupted, so that in-flight
+// requests and retry waits are aborted. Like the context of commandContext, it is released once done.
+func (c *kustoClient) stoppable(ctx context.Context) context.Context {
+	if c.stop == nil || c.stop.Done() == nil {
+		return ctx
+	}
+
+	stoppable, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-stoppable.Done():
+		case <-c.stop.Done():
+			log.Printf("[DEBUG] Cancelling management commands on %s: Terraform is stopping", c.endpoint)
+		}
+		cancel()
+	}()
+	return stoppable
+}
+
+// commandContext caps the deadline of ctx at maxCommandTimeout. The capped context must outlive Mgmt since
+// the returned RowIterator reads from it, so it is released once either context is done.
+func commandContext(ctx context.Context) context.Context {
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) <= maxCommandTimeout {
+		return ctx
+	}
+
+	capped, cancel := context.WithTimeout(ctx, maxCommandTimeout)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-capped.Done():
+		}
+		cancel()
+	}()
+	return capped
+}
+
+// retryableMessages are fragments of Kusto HTTP errors which indicate throttling or a temporarily
+// unavailable service. The SDK reports the HTTP status in parentheses, e.g. "(429 Too Many Requests)".
+var retryableMessages = []string{
+	"(429",
+	"too many requests",
+	"toomanyrequests",
+	"throttl",
+	"(502",
+	"(503",
+	"service unavailable",
+	"serviceunavailable",
+	"(504",
+}
+
+// isRetryableError classifies errors returned by the Kusto SDK. Only errors the SDK considers transient
+// are candidates, and of those only throttling, unavailability, timeouts and network errors are retried,
+// since the SDK doesn't mark every semantic error as permanent.
+func isRetryableError(err error) bool {
+	if !errors.Retry(err) {
+		return false
+	}
+
+	var kErr *errors.Error
+	if stderrors.As(err, &kErr) && kErr.Kind == errors.KTimeout {
+		return true
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range retryableMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,286 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// testClient returns the client for the test server at config.Endpoint, without authentication.
+func testClient(t *testing.T, config *Config, stop context.Context) *kustoClient {
+	config.AuthMode = authModeNone
+	meta, diags := config.Client(stop, "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(config.Endpoint)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return client
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := retryPolicy{MaxRetries: 10, BaseWait: time.Second, MaxWait: 10 * time.Second}
+
+	cases := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{2, 2 * time.Second, 4 * time.Second},
+		{5, 5 * time.Second, 10 * time.Second},
+		{100, 5 * time.Second, 10 * time.Second},
+	}
+
+	for _, tc := range cases {
+		for i := 0; i < 20; i++ {
+			if got := policy.backoff(tc.attempt); got < tc.min || got > tc.max {
+				t.Fatalf("attempt %d: expected backoff between %s and %s, got %s", tc.attempt, tc.min, tc.max, got)
+			}
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{errors.ES(errors.OpMgmt, errors.KHTTPError, "error from Kusto endpoint for query \".show tables\": (429 Too Many Requests):"), true},
+		{errors.ES(errors.OpMgmt, errors.KHTTPError, "error from Kusto endpoint for query \".show tables\": (503 Service Unavailable):"), true},
+		{errors.ES(errors.OpMgmt, errors.KTimeout, "request timed out"), true},
+		{errors.ES(errors.OpMgmt, errors.KHTTPError, "error from Kusto endpoint for query \".show table T429\": (400 Bad Request): Syntax error"), false},
+		{errors.ES(errors.OpMgmt, errors.KHTTPError, "(503 Service Unavailable)").SetNoRetry(), false},
+		{errors.ES(errors.OpMgmt, errors.KClientArgs, "too many requests"), false},
+		{fmt.Errorf("(429 Too Many Requests)"), false},
+	}
+
+	for _, tc := range cases {
+		if got := isRetryableError(tc.err); got != tc.retryable {
+			t.Errorf("%v: expected retryable to be %t, got %t", tc.err, tc.retryable, got)
+		}
+	}
+}
+
+func TestKustoClient_MgmtRetries(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"code":"TooManyRequests","message":"throttled"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+	defer server.Close()
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL, MaxRetries: 2}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.retry.BaseWait = time.Millisecond
+	client.retry.MaxWait = 10 * time.Millisecond
+
+	resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		t.Fatalf("expected throttled command to succeed after retrying, got: %s", err)
+	}
+	resp.Stop()
+
+	if requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests)
+	}
+
+	requests = 0
+	client.retry.MaxRetries = 1
+	if _, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show tables")); err == nil {
+		t.Fatalf("expected an error once max_retries is exhausted")
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestCommandContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Hour)
+	defer cancel()
+
+	deadline, ok := commandContext(ctx).Deadline()
+	if !ok || time.Until(deadline) > maxCommandTimeout {
+		t.Fatalf("expected deadline to be capped at %s, got %s", maxCommandTimeout, time.Until(deadline))
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if got := commandContext(short); got != short {
+		t.Fatalf("expected contexts with a deadline within %s to be used as-is", maxCommandTimeout)
+	}
+}
+
+func TestKustoClient_MgmtCancelledOnStop(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	stop, interrupt := context.WithCancel(context.Background())
+	client := testClient(t, &Config{Endpoint: server.URL}, stop)
+	client.retry.MaxRetries = 0
+
+	time.AfterFunc(50*time.Millisecond, interrupt)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".create table T (a:string)"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected an error when Terraform is interrupted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the command to be cancelled when Terraform is interrupted")
+	}
+}
+
+func TestKustoClient_MgmtServerTimeout(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+	defer server.Close()
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	resp, err := client.Mgmt(ctx, "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Stop()
+
+	if !strings.Contains(body, "servertimeout") {
+		t.Fatalf("expected the request to carry a server timeout, got: %s", body)
+	}
+}
+
+func TestKustoClient_requestHeaders(t *testing.T) {
+	var header http.Header
+	server := testKustoServer(t, func(r *http.Request) {
+		header = r.Header.Clone()
+	})
+	defer server.Close()
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL, MaxConcurrentCommands: 1}).Client(context.Background(), "Terraform/1.0.0 terraform-provider-adx/dev")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Stop()
+
+	requestID := header.Get("x-ms-client-request-id")
+	if !strings.HasPrefix(requestID, "terraform-provider-adx;") {
+		t.Fatalf("expected a terraform-provider-adx client request ID, got %q", requestID)
+	}
+	conn := <-client.connections
+	defer func() { client.connections <- conn }()
+	if got := conn.requests.LastRequestID(); got != requestID {
+		t.Fatalf("expected the client request ID %q to be recorded, got %q", requestID, got)
+	}
+	if got := header.Get("x-ms-app"); got != "terraform-provider-adx" {
+		t.Fatalf("expected x-ms-app to be terraform-provider-adx, got %q", got)
+	}
+	if got := header.Get("User-Agent"); got != "Terraform/1.0.0 terraform-provider-adx/dev" {
+		t.Fatalf("expected the provider's user agent, got %q", got)
+	}
+}
+
+func TestKustoClient_MgmtMaxConcurrentCommands(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxRunning int
+	server := testKustoServer(t, func(r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	defer server.Close()
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL, MaxConcurrentCommands: 2}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show tables"))
+			if err != nil {
+				t.Errorf("err: %s", err)
+				return
+			}
+			resp.Stop()
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 2 {
+		t.Fatalf("expected 2 commands to run concurrently, got %d", maxRunning)
+	}
+}
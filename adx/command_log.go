@@ -0,0 +1,108 @@
+package adx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commandLogEntry records a single execution of a management command. Retried commands are recorded once
+// per attempt.
+type commandLogEntry struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Endpoint        string    `json:"endpoint"`
+	Database        string    `json:"database"`
+	Command         string    `json:"command"`
+	DurationMs      int64     `json:"duration_ms"`
+	ClientRequestID string    `json:"client_request_id"`
+	Result          string    `json:"result"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// commandLogger writes every executed management command to the debug log and, if path is set, appends
+// it as a JSON line to that file. Commands and errors are redacted before they are recorded.
+type commandLogger struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newCommandLogger(path string) (*commandLogger, error) {
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error opening command_log_path %q: %+v", path, err)
+		}
+		f.Close()
+	}
+
+	return &commandLogger{path: path}, nil
+}
+
+func (l *commandLogger) record(endpoint string, db string, command string, start time.Time, clientRequestID string, err error) {
+	entry := commandLogEntry{
+		Timestamp:       start.UTC(),
+		Endpoint:        endpoint,
+		Database:        db,
+		Command:         redactCommand(command),
+		DurationMs:      time.Since(start).Milliseconds(),
+		ClientRequestID: clientRequestID,
+		Result:          "Succeeded",
+	}
+	if err != nil {
+		entry.Result = "Failed"
+		entry.Error = redactCommand(err.Error())
+	}
+
+	line, jsonErr := json.Marshal(entry)
+	if jsonErr != nil {
+		log.Printf("[WARN] Unable to encode command log entry: %+v", jsonErr)
+		return
+	}
+	log.Printf("[DEBUG] Executed management command: %s", line)
+
+	if l.path == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, fileErr := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if fileErr != nil {
+		log.Printf("[WARN] Unable to open command_log_path %q: %+v", l.path, fileErr)
+		return
+	}
+	defer f.Close()
+
+	if _, fileErr := f.Write(append(line, '\n')); fileErr != nil {
+		log.Printf("[WARN] Unable to write to command_log_path %q: %+v", l.path, fileErr)
+	}
+}
+
+const redacted = "****"
+
+var (
+	// secretAssignments matches key=value secrets in connection strings and SAS tokens.
+	secretAssignments = regexp.MustCompile(`(?i)\b(AccountKey|SharedAccessKey|Password|Pwd|Secret|ClientSecret|AppKey|Application Key|sig|token)=([^;&'"\s]+)`)
+	// storageKeys matches the `;<key>` suffix of storage connection strings such as `h'https://account.blob.core.windows.net/container;<key>'`.
+	storageKeys = regexp.MustCompile(`(https?://[^;'"\s]+);([^'"\s]+)`)
+)
+
+// redactCommand removes storage keys, SAS signatures and passwords from a command or an error describing it.
+func redactCommand(command string) string {
+	command = secretAssignments.ReplaceAllString(command, "${1}="+redacted)
+
+	return storageKeys.ReplaceAllStringFunc(command, func(match string) string {
+		parts := storageKeys.FindStringSubmatch(match)
+		// Identity based authentication keywords aren't secrets
+		if suffix := strings.ToLower(parts[2]); suffix == "impersonate" || strings.HasPrefix(suffix, "managed_identity=") {
+			return match
+		}
+		return parts[1] + ";" + redacted
+	})
+}
@@ -0,0 +1,106 @@
+package adx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+func TestRedactCommand(t *testing.T) {
+	cases := []struct {
+		command  string
+		expected string
+	}{
+		{
+			".create table T ingestion json mapping 'M' '[{\"column\":\"a\",\"path\":\"$.a\"}]'",
+			".create table T ingestion json mapping 'M' '[{\"column\":\"a\",\"path\":\"$.a\"}]'",
+		},
+		{
+			".create external table T (a:string) kind=storage dataformat=csv (h@'https://account.blob.core.windows.net/container;c2VjcmV0a2V5')",
+			".create external table T (a:string) kind=storage dataformat=csv (h@'https://account.blob.core.windows.net/container;****')",
+		},
+		{
+			".create external table T (a:string) kind=storage dataformat=csv (h@'https://account.blob.core.windows.net/container;impersonate')",
+			".create external table T (a:string) kind=storage dataformat=csv (h@'https://account.blob.core.windows.net/container;impersonate')",
+		},
+		{
+			".ingest into table T (h'https://account.blob.core.windows.net/container/file.csv?sv=2020-08-04&sig=c2lnbmF0dXJl')",
+			".ingest into table T (h'https://account.blob.core.windows.net/container/file.csv?sv=2020-08-04&sig=****')",
+		},
+		{
+			"h@'DefaultEndpointsProtocol=https;AccountName=account;AccountKey=a2V5PQ==;EndpointSuffix=core.windows.net'",
+			"h@'DefaultEndpointsProtocol=https;AccountName=account;AccountKey=****;EndpointSuffix=core.windows.net'",
+		},
+		{
+			"h@'Server=tcp:sql.database.windows.net;Database=db;User ID=user;Password=hunter2'",
+			"h@'Server=tcp:sql.database.windows.net;Database=db;User ID=user;Password=****'",
+		},
+	}
+
+	for _, tc := range cases {
+		if got := redactCommand(tc.command); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestCommandLog(t *testing.T) {
+	server := testKustoServer(t, func(r *http.Request) {})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "commands.jsonl")
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL, CommandLogPath: path}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	command := ".create external table T (a:string) kind=storage dataformat=csv (h@'https://account.blob.core.windows.net/container;c2VjcmV0a2V5')"
+	resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt("", kusto.UnsafeStmt(unsafe.Stmt{Add: true})).UnsafeAdd(command))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Stop()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var entries []commandLogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry commandLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("error decoding %q: %+v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Endpoint != server.URL || entry.Database != "test-db" || entry.Result != "Succeeded" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if strings.Contains(entry.Command, "c2VjcmV0a2V5") || !strings.HasPrefix(entry.Command, ".create external table T") {
+		t.Fatalf("expected the storage key to be redacted, got %q", entry.Command)
+	}
+	if entry.ClientRequestID == "" {
+		t.Fatalf("expected the client request ID to be recorded")
+	}
+}
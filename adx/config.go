@@ -2,36 +2,524 @@ package adx
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 )
 
+const (
+	// authModeAuto selects the authentication method from the other provider attributes.
+	authModeAuto = "auto"
+	// authModeNone sends unauthenticated requests, e.g. to a local Kusto emulator.
+	authModeNone = "none"
+)
+
 type Config struct {
+	AuthMode string
+
+	MaxRetries   int
+	RetryMaxWait time.Duration
+
+	MaxConcurrentCommands int
+
+	BatchCommands bool
+	BatchWindow   time.Duration
+
+	CommandLogPath string
+
+	ClientRequestProperties map[string]string
+
+	ProxyURL          string
+	CACertificatePath string
+	CACertificate     string
+	TLSMinVersion     string
+
+	Environment   string
+	AuthorityHost string
+	TokenAudience string
+
 	ClientID     string
 	ClientSecret string
 	TenantID     string
 	Endpoint     string
+
+	AccessToken  string
+	TokenCommand []string
+
+	UseMSI      bool
+	MSIClientID string
+	MSIEndpoint string
+
+	UseCLI  bool
+	CLIPath string
+
+	ClientCertificatePath     string
+	ClientCertificate         string
+	ClientCertificatePassword string
+
+	UseOIDC           bool
+	OIDCTokenFilePath string
+	OIDCRequestURL    string
+	OIDCRequestToken  string
+	OIDCTokenEndpoint string
+
+	// httpClient is set up by Client from the proxy and TLS settings, if there are any.
+	httpClient *http.Client
 }
 
 type Meta struct {
-	Kusto       kusto.Client
+	// Endpoint is the provider's adx_endpoint, used by resources which don't set cluster_uri.
+	Endpoint    string
 	StopContext context.Context
+
+	config     Config
+	userAgent  string
+	commandLog *commandLogger
+	// databaseLocks serializes metadata changes per database across all clusters, and schemas caches the
+	// schema of each database for the run.
+	databaseLocks *keyedMutex
+	schemas       *schemaCache
+
+	mu      sync.Mutex
+	clients map[string]*kustoClient
 }
 
-func (c *Config) Client(userAgent string) (*Meta, diag.Diagnostics) {
-	meta := Meta{
-		StopContext: context.Background(),
+// Client builds the provider's Meta. stopContext is cancelled when Terraform is interrupted, which aborts
+// the commands in flight.
+func (c *Config) Client(stopContext context.Context, userAgent string) (*Meta, diag.Diagnostics) {
+	commandLog, err := newCommandLogger(c.CommandLogPath)
+	if err != nil {
+		return nil, diag.FromErr(err)
 	}
 
-	auth := kusto.Authorization{Config: auth.NewClientCredentialsConfig(c.ClientID, c.ClientSecret, c.TenantID)}
-	client, err := kusto.New(c.Endpoint, auth)
-	if err != nil {
+	if c.httpClient, err = c.buildHTTPClient(); err != nil {
 		return nil, diag.FromErr(err)
 	}
 
-	meta.Kusto = *client
+	meta := Meta{
+		Endpoint:    c.Endpoint,
+		StopContext: stopContext,
+		config:      *c,
+		userAgent:   userAgent,
+		commandLog:  commandLog,
+		clients:     map[string]*kustoClient{},
+
+		databaseLocks: newKeyedMutex(),
+		schemas:       newSchemaCache(),
+	}
+
+	// Set up the default cluster eagerly so configuration errors surface when the provider is configured
+	if c.Endpoint != "" {
+		if _, err := meta.Client(c.Endpoint); err != nil {
+			return nil, diag.FromErr(err)
+		}
+	}
 
 	return &meta, nil
 }
+
+// ClusterEndpoint returns the endpoint a resource should be managed on: its cluster_uri if set, otherwise
+// the provider's adx_endpoint, normalized.
+func (m *Meta) ClusterEndpoint(clusterURI string) string {
+	if clusterURI != "" {
+		return normalizeEndpoint(clusterURI)
+	}
+	return normalizeEndpoint(m.Endpoint)
+}
+
+// Client returns the Kusto client for endpoint, creating it on first use. Clients for all endpoints share
+// the provider's credentials, with tokens requested for each cluster.
+func (m *Meta) Client(endpoint string) (*kustoClient, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("cluster_uri must be set when the provider has no adx_endpoint configured")
+	}
+
+	endpoint = normalizeEndpoint(endpoint)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if client, ok := m.clients[endpoint]; ok {
+		return client, nil
+	}
+
+	config := m.config
+	config.Endpoint = endpoint
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		return nil, err
+	}
+
+	size := m.config.MaxConcurrentCommands
+	if size < 1 {
+		size = defaultMaxConcurrentCommands
+	}
+
+	// All connections share the authorizer, and so its tokens
+	connections := make(chan *kustoConnection, size)
+	for i := 0; i < size; i++ {
+		requests := &requestAuthorizer{Authorizer: authorizer, userAgent: m.userAgent}
+
+		client, err := newKustoClient(endpoint, requests, m.config.httpClient)
+		if err != nil {
+			return nil, err
+		}
+		connections <- &kustoConnection{Client: client, requests: requests}
+	}
+
+	m.clients[endpoint] = &kustoClient{
+		endpoint:    endpoint,
+		stop:        m.StopContext,
+		commandLog:  m.commandLog,
+		properties:  m.config.ClientRequestProperties,
+		connections: connections,
+
+		databaseLocks: m.databaseLocks,
+		schemas:       m.schemas,
+		retry: retryPolicy{
+			MaxRetries: m.config.MaxRetries,
+			BaseWait:   defaultRetryBaseWait,
+			MaxWait:    m.config.RetryMaxWait,
+		},
+	}
+
+	if m.config.BatchCommands {
+		m.clients[endpoint].batcher = newCommandBatcher(m.clients[endpoint], m.config.BatchWindow)
+	}
+
+	return m.clients[endpoint], nil
+}
+
+// authorizer builds the bearer authorizer for the configured authentication mode. Tokens are always
+// requested for the cluster endpoint itself.
+func (c *Config) authorizer() (autorest.Authorizer, error) {
+	switch {
+	case c.AuthMode == authModeNone:
+		return autorest.NullAuthorizer{}, nil
+	case c.AccessToken != "":
+		return autorest.NewBearerAuthorizer(staticToken(c.AccessToken)), nil
+	case len(c.TokenCommand) != 0:
+		return c.tokenCommandAuthorizer()
+	case c.UseMSI:
+		return c.msiAuthorizer()
+	case c.UseCLI:
+		return c.cliAuthorizer()
+	case c.UseOIDC:
+		return c.oidcAuthorizer()
+	case c.ClientCertificatePath != "" || c.ClientCertificate != "":
+		return c.certificateAuthorizer()
+	}
+
+	if c.ClientID == "" || c.ClientSecret == "" || c.TenantID == "" {
+		return nil, fmt.Errorf("client_id, client_secret and tenant_id must be set unless access_token, token_command, use_msi, use_cli, use_oidc or a client certificate is configured")
+	}
+
+	cloud, err := c.cloud()
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := auth.NewClientCredentialsConfig(c.ClientID, c.ClientSecret, c.TenantID)
+	credentials.AADEndpoint = cloud.AuthorityHost
+	credentials.Resource = c.resource()
+
+	spt, err := credentials.ServicePrincipalToken()
+	if err != nil {
+		return nil, err
+	}
+	spt.SetSender(c.sender())
+
+	return autorest.NewBearerAuthorizer(spt), nil
+}
+
+// cloudEnvironment holds the AAD settings which differ between Azure clouds.
+type cloudEnvironment struct {
+	AuthorityHost string
+	// FederatedTokenAudience is the audience AAD expects on federated (OIDC) tokens.
+	FederatedTokenAudience string
+}
+
+var cloudEnvironments = map[string]cloudEnvironment{
+	"public": {
+		AuthorityHost:          "https://login.microsoftonline.com/",
+		FederatedTokenAudience: "api://AzureADTokenExchange",
+	},
+	"usgovernment": {
+		AuthorityHost:          "https://login.microsoftonline.us/",
+		FederatedTokenAudience: "api://AzureADTokenExchangeUSGov",
+	},
+	"china": {
+		AuthorityHost:          "https://login.chinacloudapi.cn/",
+		FederatedTokenAudience: "api://AzureADTokenExchangeChina",
+	},
+}
+
+// armEnvironments maps the ARM_ENVIRONMENT values of the azurerm provider, and the Azure SDK cloud names, to
+// the environments above.
+var armEnvironments = map[string]string{
+	"public":                 "public",
+	"azurepubliccloud":       "public",
+	"usgovernment":           "usgovernment",
+	"azureusgovernmentcloud": "usgovernment",
+	"china":                  "china",
+	"azurechinacloud":        "china",
+}
+
+// environmentDefault is the default of the environment attribute: ADX_ENVIRONMENT, then ARM_ENVIRONMENT mapped
+// from its azurerm name, then public. ARM_ENVIRONMENT values without a counterpart, such as german, are ignored
+// rather than failing the validation of configurations which share their environment with azurerm.
+func environmentDefault() (interface{}, error) {
+	if env := os.Getenv("ADX_ENVIRONMENT"); env != "" {
+		return env, nil
+	}
+	if env := os.Getenv("ARM_ENVIRONMENT"); env != "" {
+		if mapped, ok := armEnvironments[strings.ToLower(env)]; ok {
+			return mapped, nil
+		}
+		log.Printf("[WARN] Ignoring ARM_ENVIRONMENT %q: it has no matching environment", env)
+	}
+	return "public", nil
+}
+
+// cloud returns the AAD settings for the configured environment. The custom environment only takes its
+// authority host from the configuration.
+func (c *Config) cloud() (cloudEnvironment, error) {
+	env := c.Environment
+	if env == "" {
+		env = "public"
+	}
+
+	if env == "custom" {
+		if c.AuthorityHost == "" {
+			return cloudEnvironment{}, fmt.Errorf("authority_host must be set when environment is %q", env)
+		}
+		return cloudEnvironment{
+			AuthorityHost:          strings.TrimSuffix(c.AuthorityHost, "/") + "/",
+			FederatedTokenAudience: cloudEnvironments["public"].FederatedTokenAudience,
+		}, nil
+	}
+
+	cloud, ok := cloudEnvironments[env]
+	if !ok {
+		return cloudEnvironment{}, fmt.Errorf("unknown environment %q", env)
+	}
+	if c.AuthorityHost != "" {
+		cloud.AuthorityHost = strings.TrimSuffix(c.AuthorityHost, "/") + "/"
+	}
+	return cloud, nil
+}
+
+// resource is the audience tokens are requested for, which is the cluster itself unless overridden.
+func (c *Config) resource() string {
+	if c.TokenAudience != "" {
+		return c.TokenAudience
+	}
+	return c.Endpoint
+}
+
+// environmentVariables are read for the endpoint and credentials which aren't configured, unless a
+// connection string is set. The first variable which is set wins.
+var environmentVariables = []struct {
+	names []string
+	field func(c *Config) *string
+}{
+	{[]string{"ADX_ENDPOINT"}, func(c *Config) *string { return &c.Endpoint }},
+	{[]string{"ADX_CLIENT_ID", "ARM_CLIENT_ID"}, func(c *Config) *string { return &c.ClientID }},
+	{[]string{"ADX_CLIENT_SECRET", "ARM_CLIENT_SECRET"}, func(c *Config) *string { return &c.ClientSecret }},
+	{[]string{"ADX_TENANT_ID", "ARM_TENANT_ID"}, func(c *Config) *string { return &c.TenantID }},
+	{[]string{"ADX_ACCESS_TOKEN"}, func(c *Config) *string { return &c.AccessToken }},
+}
+
+// applyEnvironment fills in the endpoint and credentials which aren't set yet from environment variables.
+func (c *Config) applyEnvironment() {
+	for _, v := range environmentVariables {
+		field := v.field(c)
+		for _, name := range v.names {
+			if *field != "" {
+				break
+			}
+			*field = os.Getenv(name)
+		}
+	}
+}
+
+// applyConnectionString fills in the fields which aren't set yet from a Kusto connection string, e.g.
+// `Data Source=https://mycluster.kusto.windows.net;Fed=True;Application Client Id=...;Application Key=...;Authority Id=...`.
+// Keywords are matched case-insensitively and unknown keywords, such as the initial catalog, are ignored.
+func (c *Config) applyConnectionString(connectionString string) error {
+	setIfEmpty := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	pairs, err := parseConnectionString(connectionString)
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range pairs {
+		key := strings.ToLower(strings.Join(strings.Fields(kv[0]), " "))
+		value := kv[1]
+
+		switch key {
+		case "data source", "addr", "address", "network address", "server":
+			setIfEmpty(&c.Endpoint, value)
+		case "application client id", "appclientid":
+			setIfEmpty(&c.ClientID, value)
+		case "application key", "appkey":
+			setIfEmpty(&c.ClientSecret, value)
+		case "authority id", "authority", "tenantid":
+			setIfEmpty(&c.TenantID, value)
+		case "application token", "apptoken", "user token", "usertoken", "usrtoken":
+			setIfEmpty(&c.AccessToken, value)
+		case "fed", "federated security", "aad federated security":
+			fed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("error parsing connection string: invalid value %q for %q", value, kv[0])
+			}
+			if !fed && c.AuthMode == authModeAuto {
+				c.AuthMode = authModeNone
+			}
+		case "azcli":
+			cli, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("error parsing connection string: invalid value %q for %q", value, kv[0])
+			}
+			c.UseCLI = c.UseCLI || cli
+		case "application certificate thumbprint", "appcert":
+			return fmt.Errorf("error parsing connection string: certificate thumbprints are not supported, use client_certificate_path instead")
+		}
+	}
+
+	return nil
+}
+
+// parseConnectionString splits a connection string into keyword/value pairs. Values may be quoted with `"`
+// or `'` to contain `;`, and a quote is escaped inside a value quoted with it by doubling it.
+func parseConnectionString(connectionString string) ([][2]string, error) {
+	var pairs [][2]string
+
+	rest := connectionString
+	for strings.TrimSpace(rest) != "" {
+		eq := strings.IndexAny(rest, "=;")
+		if eq < 0 || rest[eq] != '=' {
+			pair := rest
+			if eq >= 0 {
+				pair = rest[:eq]
+			}
+			if strings.TrimSpace(pair) == "" {
+				rest = rest[eq+1:]
+				continue
+			}
+			return nil, fmt.Errorf("error parsing connection string: expected keyword=value, got %q", pair)
+		}
+		key := rest[:eq]
+		rest = strings.TrimLeft(rest[eq+1:], " \t")
+
+		var value string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			quote := rest[0]
+			var b strings.Builder
+			i := 1
+			for {
+				if i >= len(rest) {
+					return nil, fmt.Errorf("error parsing connection string: unterminated quoted value for %q", strings.TrimSpace(key))
+				}
+				if rest[i] == quote {
+					if i+1 < len(rest) && rest[i+1] == quote {
+						b.WriteByte(quote)
+						i += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(rest[i])
+				i++
+			}
+			value = b.String()
+			rest = strings.TrimLeft(rest[i+1:], " \t")
+			if rest != "" && rest[0] != ';' {
+				return nil, fmt.Errorf("error parsing connection string: unexpected %q after quoted value for %q", rest, strings.TrimSpace(key))
+			}
+		} else {
+			end := strings.IndexByte(rest, ';')
+			if end < 0 {
+				end = len(rest)
+			}
+			value = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+		rest = strings.TrimPrefix(rest, ";")
+
+		pairs = append(pairs, [2]string{key, value})
+	}
+
+	return pairs, nil
+}
+
+// emulatorEndpoint is handed to the Kusto SDK in place of endpoints it refuses, such as the plain HTTP
+// endpoint of a local emulator. Requests are redirected to the real endpoint by endpointAuthorizer.
+const emulatorEndpoint = "https://emulator.kusto.local"
+
+// newKustoClient creates an SDK client for endpoint. Its requests are sent with httpClient, if set.
+func newKustoClient(endpoint string, authorizer autorest.Authorizer, httpClient *http.Client) (*kusto.Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing endpoint %q: %+v", endpoint, err)
+	}
+
+	var client *kusto.Client
+	// The SDK only accepts https endpoints and drops the port of the endpoint it is given
+	if u.Scheme == "https" && u.Port() == "" {
+		client, err = kusto.New(endpoint, kusto.Authorization{Authorizer: authorizer})
+	} else {
+		client, err = kusto.New(emulatorEndpoint, kusto.Authorization{Authorizer: endpointAuthorizer{Authorizer: authorizer, target: u}})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if httpClient != nil {
+		if err := setHTTPClient(client, httpClient); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// endpointAuthorizer wraps an Authorizer and sends its requests to target instead of the endpoint
+// the SDK was created with.
+type endpointAuthorizer struct {
+	autorest.Authorizer
+	target *url.URL
+}
+
+func (a endpointAuthorizer) WithAuthorization() autorest.PrepareDecorator {
+	authorize := a.Authorizer.WithAuthorization()
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			r, err := authorize(p).Prepare(r)
+			if err != nil {
+				return r, err
+			}
+			r.URL.Scheme = a.target.Scheme
+			r.URL.Host = a.target.Host
+			r.Host = a.target.Host
+			return r, nil
+		})
+	}
+}
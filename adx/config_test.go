@@ -0,0 +1,481 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const testClusterEndpoint = "https://test.westeurope.kusto.windows.net"
+
+func testAuthorizationHeader(t *testing.T, authorizer autorest.Authorizer) string {
+	req, err := http.NewRequest(http.MethodPost, testClusterEndpoint+"/v1/rest/mgmt", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	req, err = autorest.Prepare(req, authorizer.WithAuthorization())
+	if err != nil {
+		t.Fatalf("err preparing request: %s", err)
+	}
+
+	return req.Header.Get("Authorization")
+}
+
+func testTokenServer(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		check(r)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"test-token","expires_on":"%d","token_type":"Bearer"}`, time.Now().Add(time.Hour).Unix())
+	}))
+}
+
+// testKustoServer stands in for a Kusto cluster (or emulator) and answers every management command with
+// an empty result.
+func testKustoServer(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/rest/mgmt" {
+			http.NotFound(w, r)
+			return
+		}
+		check(r)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+}
+
+func TestConfig_emulator(t *testing.T) {
+	var requests int
+	server := testKustoServer(t, func(r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("expected no Authorization header, got %q", got)
+		}
+	})
+	defer server.Close()
+
+	config := &Config{
+		AuthMode: authModeNone,
+		Endpoint: server.URL,
+	}
+
+	meta, diags := config.Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	if meta.Endpoint != server.URL {
+		t.Fatalf("expected endpoint %q, got %q", server.URL, meta.Endpoint)
+	}
+
+	client, err := meta.Client(meta.Endpoint)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show databases"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Stop()
+
+	if requests != 1 {
+		t.Fatalf("expected 1 request to the emulator, got %d", requests)
+	}
+}
+
+func TestMeta_Client(t *testing.T) {
+	config := &Config{
+		AuthMode: authModeNone,
+		Endpoint: "http://localhost:8080",
+	}
+
+	meta, diags := config.Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	first, err := meta.Client(meta.ClusterEndpoint(""))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	second, err := meta.Client("http://localhost:8080")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if first != second {
+		t.Fatalf("expected the client for an endpoint to be reused")
+	}
+
+	other, err := meta.Client(meta.ClusterEndpoint("http://localhost:8081"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if other == first {
+		t.Fatalf("expected a separate client for a different cluster_uri")
+	}
+
+	meta.Endpoint = ""
+	if _, err := meta.Client(meta.ClusterEndpoint("")); err == nil {
+		t.Fatalf("expected an error without cluster_uri or adx_endpoint")
+	}
+}
+
+func TestConfig_msiAuthorizer(t *testing.T) {
+	server := testTokenServer(t, func(r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			t.Errorf("expected Metadata header to be set")
+		}
+		if got := r.URL.Query().Get("resource"); got != testClusterEndpoint {
+			t.Errorf("expected resource %q, got %q", testClusterEndpoint, got)
+		}
+		if got := r.URL.Query().Get("client_id"); got != "user-assigned-id" {
+			t.Errorf("expected client_id %q, got %q", "user-assigned-id", got)
+		}
+	})
+	defer server.Close()
+
+	config := &Config{
+		Endpoint:    testClusterEndpoint,
+		UseMSI:      true,
+		MSIClientID: "user-assigned-id",
+		MSIEndpoint: server.URL,
+	}
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer test-token" {
+		t.Fatalf("expected bearer token from managed identity endpoint, got %q", got)
+	}
+}
+
+func TestConfig_cliAuthorizer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake Azure CLI is a shell script")
+	}
+
+	// The fake CLI only answers when asked for a token for the cluster
+	script := fmt.Sprintf(`#!/bin/sh
+[ "$4" = "%s" ] || exit 1
+echo '{"accessToken":"cli-token","expiresOn":"2000-01-01 00:00:00.000000","expires_on":%d}'
+`, testClusterEndpoint, time.Now().Add(time.Hour).Unix())
+
+	cliPath := filepath.Join(t.TempDir(), "az")
+	if err := ioutil.WriteFile(cliPath, []byte(script), 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := &Config{
+		Endpoint: testClusterEndpoint,
+		UseCLI:   true,
+		CLIPath:  cliPath,
+	}
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer cli-token" {
+		t.Fatalf("expected bearer token from Azure CLI, got %q", got)
+	}
+}
+
+func TestConfig_oidcAuthorizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/idtoken":
+			if r.Header.Get("Authorization") != "Bearer request-token" {
+				t.Errorf("expected OIDC request token to be sent")
+			}
+			if got := r.URL.Query().Get("audience"); got != "api://AzureADTokenExchangeUSGov" {
+				t.Errorf("expected audience for US Government, got %q", got)
+			}
+			fmt.Fprint(w, `{"value":"federated-token"}`)
+		case "/token":
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("err: %s", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if got := r.PostForm.Get("client_assertion"); got != "federated-token" {
+				t.Errorf("expected client_assertion %q, got %q", "federated-token", got)
+			}
+			if got := r.PostForm.Get("scope"); got != testClusterEndpoint+"/.default" {
+				t.Errorf("expected scope for the cluster, got %q", got)
+			}
+			fmt.Fprint(w, `{"access_token":"oidc-token","expires_in":3599,"token_type":"Bearer"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Endpoint:          testClusterEndpoint,
+		ClientID:          "client",
+		TenantID:          "tenant",
+		Environment:       "usgovernment",
+		UseOIDC:           true,
+		OIDCRequestURL:    server.URL + "/idtoken",
+		OIDCRequestToken:  "request-token",
+		OIDCTokenEndpoint: server.URL + "/token",
+	}
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer oidc-token" {
+		t.Fatalf("expected bearer token from OIDC exchange, got %q", got)
+	}
+
+	// Federated tokens can also be read from a file
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("federated-token\n"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config.OIDCRequestURL = ""
+	config.OIDCTokenFilePath = tokenFile
+
+	if authorizer, err = config.authorizer(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer oidc-token" {
+		t.Fatalf("expected bearer token from OIDC exchange, got %q", got)
+	}
+}
+
+func TestConfig_accessToken(t *testing.T) {
+	config := &Config{
+		Endpoint:    testClusterEndpoint,
+		AccessToken: "static-token",
+	}
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer static-token" {
+		t.Fatalf("expected static bearer token, got %q", got)
+	}
+}
+
+func TestConfig_tokenCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("token command uses echo")
+	}
+
+	config := &Config{
+		Endpoint:     testClusterEndpoint,
+		TokenCommand: []string{"echo", "command-token"},
+	}
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer command-token" {
+		t.Fatalf("expected bearer token from token_command, got %q", got)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	// {"alg":"none"}.{"exp":1700000000}.
+	jwt := "eyJhbGciOiJub25lIn0.eyJleHAiOjE3MDAwMDAwMDB9.sig"
+	if got := tokenExpiry(jwt); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("expected expiry from exp claim, got %s", got)
+	}
+
+	if got := tokenExpiry("opaque"); got.Before(time.Now().Add(opaqueTokenLifetime - time.Minute)) {
+		t.Fatalf("expected opaque tokens to be assumed valid for %s, got %s", opaqueTokenLifetime, got)
+	}
+}
+
+func TestConfig_applyConnectionString(t *testing.T) {
+	config := &Config{
+		AuthMode: authModeAuto,
+		TenantID: "explicit-tenant",
+	}
+
+	err := config.applyConnectionString("Data Source=" + testClusterEndpoint + ";Initial Catalog=test-db;Fed=True;Application Client Id=client;application key=secret;Authority Id=tenant")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if config.Endpoint != testClusterEndpoint {
+		t.Errorf("expected endpoint %q, got %q", testClusterEndpoint, config.Endpoint)
+	}
+	if config.ClientID != "client" || config.ClientSecret != "secret" {
+		t.Errorf("expected client credentials from connection string, got %q/%q", config.ClientID, config.ClientSecret)
+	}
+	if config.TenantID != "explicit-tenant" {
+		t.Errorf("expected explicitly configured tenant to take precedence, got %q", config.TenantID)
+	}
+	if config.AuthMode != authModeAuto {
+		t.Errorf("expected auth mode %q, got %q", authModeAuto, config.AuthMode)
+	}
+
+	config = &Config{AuthMode: authModeAuto}
+	if err := config.applyConnectionString("Data Source=http://localhost:8080;Fed=False"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if config.AuthMode != authModeNone {
+		t.Errorf("expected Fed=False to select auth mode %q, got %q", authModeNone, config.AuthMode)
+	}
+
+	if err := (&Config{}).applyConnectionString("Data Source"); err == nil {
+		t.Errorf("expected an error for a malformed connection string")
+	}
+
+	config = &Config{}
+	if err := config.applyConnectionString(`Data Source=` + testClusterEndpoint + `;Application Key="se;cr""et";Application Client Id='client'`); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if config.ClientSecret != `se;cr"et` || config.ClientID != "client" {
+		t.Errorf("expected quoted values to be unquoted, got %q/%q", config.ClientID, config.ClientSecret)
+	}
+
+	if err := (&Config{}).applyConnectionString(`Application Key="secret`); err == nil {
+		t.Errorf("expected an error for an unterminated quoted value")
+	}
+}
+
+func TestProvider_connectionStringPrecedence(t *testing.T) {
+	for name, value := range map[string]string{
+		"ARM_CLIENT_ID":     "env-client",
+		"ARM_CLIENT_SECRET": "env-secret",
+		"ARM_TENANT_ID":     "env-tenant",
+	} {
+		old, ok := os.LookupEnv(name)
+		defer func(name string, old string, ok bool) {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name, old, ok)
+		os.Setenv(name, value)
+	}
+
+	configure := func(raw map[string]interface{}) *Config {
+		p := Provider()
+		if diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(raw)); diags.HasError() {
+			t.Fatalf("err: %+v", diags)
+		}
+		return &p.Meta().(*Meta).config
+	}
+
+	config := configure(map[string]interface{}{
+		"connection_string": "Data Source=" + testClusterEndpoint + ";Application Client Id=client;Application Key=secret;Authority Id=tenant",
+	})
+	if config.ClientID != "client" || config.ClientSecret != "secret" || config.TenantID != "tenant" {
+		t.Errorf("expected the credentials of the connection string, got %q/%q/%q", config.ClientID, config.ClientSecret, config.TenantID)
+	}
+
+	config = configure(map[string]interface{}{
+		"adx_endpoint": testClusterEndpoint,
+		"client_id":    "explicit-client",
+	})
+	if config.ClientID != "explicit-client" || config.ClientSecret != "env-secret" || config.TenantID != "env-tenant" {
+		t.Errorf("expected the environment to fill in credentials without a connection string, got %q/%q/%q", config.ClientID, config.ClientSecret, config.TenantID)
+	}
+}
+
+func TestConfig_customEnvironment(t *testing.T) {
+	server := testTokenServer(t, func(r *http.Request) {
+		if r.URL.Path != "/tenant/oauth2/token" {
+			t.Errorf("expected token request to the custom authority, got %q", r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if got := r.PostForm.Get("resource"); got != "https://kusto.example.com" {
+			t.Errorf("expected token audience as resource, got %q", got)
+		}
+	})
+	defer server.Close()
+
+	config := &Config{
+		Endpoint:      testClusterEndpoint,
+		Environment:   "custom",
+		AuthorityHost: server.URL,
+		TokenAudience: "https://kusto.example.com",
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		TenantID:      "tenant",
+	}
+
+	authorizer, err := config.authorizer()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testAuthorizationHeader(t, authorizer); got != "Bearer test-token" {
+		t.Fatalf("expected bearer token from custom authority, got %q", got)
+	}
+
+	config.AuthorityHost = ""
+	if _, err := config.authorizer(); err == nil {
+		t.Fatalf("expected an error when authority_host is missing for the custom environment")
+	}
+}
+
+func TestProvider_armEnvironment(t *testing.T) {
+	for _, name := range []string{"ADX_ENVIRONMENT", "ARM_ENVIRONMENT"} {
+		old, ok := os.LookupEnv(name)
+		defer func(name string, old string, ok bool) {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name, old, ok)
+		os.Unsetenv(name)
+	}
+
+	cases := map[string]string{
+		"AzureUSGovernmentCloud": "usgovernment",
+		"china":                  "china",
+		"german":                 "public",
+	}
+	for value, expected := range cases {
+		os.Setenv("ARM_ENVIRONMENT", value)
+
+		if diags := Provider().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{})); diags.HasError() {
+			t.Errorf("%q: expected the configuration to be valid, got: %+v", value, diags)
+		}
+		if env, _ := environmentDefault(); env != expected {
+			t.Errorf("%q: expected environment %q, got %q", value, expected, env)
+		}
+	}
+}
+
+func TestConfig_authorizerRequiresCredentials(t *testing.T) {
+	config := &Config{
+		Endpoint: testClusterEndpoint,
+		ClientID: "client",
+	}
+
+	if _, err := config.authorizer(); err == nil {
+		t.Fatalf("expected an error when client credentials are incomplete")
+	}
+}
@@ -0,0 +1,172 @@
+package adx
+
+import (
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// commandError is the error of a management command together with what is needed to report it. The
+// command is redacted, and so is Error, as the SDK quotes the full command in its errors. The details are
+// only shown by commandDiagnostics.
+type commandError struct {
+	Endpoint        string
+	Database        string
+	Command         string
+	ClientRequestID string
+	Err             error
+}
+
+func (e *commandError) Error() string {
+	return redactCommand(e.Err.Error())
+}
+
+func (e *commandError) Unwrap() error {
+	return e.Err
+}
+
+// kustoServiceError is the OneApi error returned by the Kusto service in the body of a failed request.
+type kustoServiceError struct {
+	Code        string
+	Message     string
+	Type        string
+	Description string
+	ActivityID  string
+}
+
+// parseKustoServiceError returns the OneApi error in err, or nil if err wasn't returned by the service.
+func parseKustoServiceError(err error) *kustoServiceError {
+	for ; err != nil; err = stderrors.Unwrap(err) {
+		kErr, ok := err.(*errors.Error)
+		if !ok || kErr.Kind != errors.KHTTPError {
+			continue
+		}
+
+		body, ok := kErr.UnmarshalREST()["error"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		serviceErr := &kustoServiceError{}
+		serviceErr.Code, _ = body["code"].(string)
+		serviceErr.Message, _ = body["message"].(string)
+		serviceErr.Type, _ = body["@type"].(string)
+		serviceErr.Description, _ = body["@message"].(string)
+		if context, ok := body["@context"].(map[string]interface{}); ok {
+			serviceErr.ActivityID, _ = context["activityId"].(string)
+		}
+		if serviceErr.Message == "" && serviceErr.Description == "" {
+			continue
+		}
+		return serviceErr
+	}
+	return nil
+}
+
+// errorAttributes infer the attribute a failed command is about from the message of its error. The first
+// of the attributes which is set on the resource is used.
+var errorAttributes = []struct {
+	message    *regexp.Regexp
+	attributes []string
+}{
+	{
+		message:    regexp.MustCompile(`(?i)data ?type|column type|scalar type|unknown type|invalid type`),
+		attributes: []string{"table_schema", "column", "mapping"},
+	},
+	{
+		message:    regexp.MustCompile(`(?i)of kind 'database' was not found|database '[^']*' (was not found|does not exist)`),
+		attributes: []string{"database_name"},
+	},
+	{
+		message:    regexp.MustCompile(`(?i)of kind 'table' was not found|table '[^']*' (was not found|does not exist)`),
+		attributes: []string{"table_name", "name"},
+	},
+	{
+		message:    regexp.MustCompile(`(?i)mapping.*(invalid|malformed|could not be parsed|failed to parse)|(invalid|malformed) .*mapping`),
+		attributes: []string{"mapping"},
+	},
+	{
+		message:    regexp.MustCompile(`(?i)principal .*(could not be resolved|was not found|is invalid)|invalid principal|\bfqn\b`),
+		attributes: []string{"fqn"},
+	},
+}
+
+func errorAttributePath(d *schema.ResourceData, message string) cty.Path {
+	if d == nil {
+		return nil
+	}
+	for _, rule := range errorAttributes {
+		if !rule.message.MatchString(message) {
+			continue
+		}
+		for _, attribute := range rule.attributes {
+			if _, ok := d.GetOk(attribute); ok {
+				return cty.GetAttrPath(attribute)
+			}
+		}
+	}
+	return nil
+}
+
+// commandDiagnostics reports the error of a management command run for the resource d. The summary is the
+// formatted message followed by the Kusto error message, and the detail lists the Kusto error code, the
+// failing command and the client request ID to quote in support requests. Errors which don't come from a
+// command are reported like diag.Errorf.
+func commandDiagnostics(d *schema.ResourceData, err error, format string, a ...interface{}) diag.Diagnostics {
+	summary := fmt.Sprintf(format, a...)
+
+	var cmdErr *commandError
+	if !stderrors.As(err, &cmdErr) {
+		return diag.Errorf("%s: %+v", summary, err)
+	}
+
+	full := strings.TrimSpace(redactCommand(cmdErr.Err.Error()))
+	message := strings.SplitN(full, "\n", 2)[0]
+	var detail []string
+
+	if serviceErr := parseKustoServiceError(err); serviceErr != nil {
+		message = serviceErr.Message
+		if message == "" {
+			message = serviceErr.Description
+		}
+
+		code := serviceErr.Code
+		if serviceErr.Type != "" {
+			code = fmt.Sprintf("%s (%s)", code, serviceErr.Type)
+		}
+		detail = append(detail, fmt.Sprintf("Kusto error: %s", code))
+		full = redactCommand(serviceErr.Description)
+		if full != "" && full != message {
+			detail = append(detail, fmt.Sprintf("Message: %s", full))
+		}
+		if serviceErr.ActivityID != "" {
+			detail = append(detail, fmt.Sprintf("Activity ID: %s", serviceErr.ActivityID))
+		}
+	} else if full != message {
+		detail = append(detail, fmt.Sprintf("Message: %s", full))
+	}
+
+	detail = append(detail, fmt.Sprintf("Command: %s", cmdErr.Command))
+	if cmdErr.Database != "" {
+		detail = append(detail, fmt.Sprintf("Database: %s", cmdErr.Database))
+	}
+	detail = append(detail, fmt.Sprintf("Cluster: %s", cmdErr.Endpoint))
+	if cmdErr.ClientRequestID != "" {
+		detail = append(detail, fmt.Sprintf("Client request ID: %s", cmdErr.ClientRequestID))
+	}
+
+	return diag.Diagnostics{
+		{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("%s: %s", summary, message),
+			Detail:        strings.Join(detail, "\n"),
+			AttributePath: errorAttributePath(d, message+"\n"+full),
+		},
+	}
+}
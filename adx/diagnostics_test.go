@@ -0,0 +1,90 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestCommandDiagnostics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":"BadRequest_SyntaxError","message":"Request is invalid and cannot be executed.",`+
+			`"@type":"Kusto.Data.Exceptions.SyntaxException","@message":"Syntax error: Unknown data type 'strin'",`+
+			`"@context":{"activityId":"activity-1"},"@permanent":true}}`)
+	}))
+	defer server.Close()
+
+	client := testClient(t, &Config{Endpoint: server.URL}, context.Background())
+	err := client.Execute(context.Background(), "test-db", kusto.NewStmt(".create table T (a:strin)"))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceADXTable().Schema, map[string]interface{}{
+		"name":          "T",
+		"database_name": "test-db",
+		"table_schema":  "a:strin",
+	})
+	diags := commandDiagnostics(d, err, "error creating Table %q (Database %q)", "T", "test-db")
+	if len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got: %+v", diags)
+	}
+
+	if expected := `error creating Table "T" (Database "test-db"): Request is invalid and cannot be executed.`; diags[0].Summary != expected {
+		t.Fatalf("expected summary %q, got %q", expected, diags[0].Summary)
+	}
+	for _, expected := range []string{
+		"Kusto error: BadRequest_SyntaxError (Kusto.Data.Exceptions.SyntaxException)",
+		"Message: Syntax error: Unknown data type 'strin'",
+		"Activity ID: activity-1",
+		"Command: .create table T (a:strin)",
+		"Client request ID: " + clientRequestIDPrefix,
+	} {
+		if !strings.Contains(diags[0].Detail, expected) {
+			t.Fatalf("expected detail to contain %q, got:\n%s", expected, diags[0].Detail)
+		}
+	}
+	if !diags[0].AttributePath.Equals(cty.GetAttrPath("table_schema")) {
+		t.Fatalf("expected the error to point to table_schema, got: %#v", diags[0].AttributePath)
+	}
+}
+
+func TestCommandDiagnostics_redactsCommand(t *testing.T) {
+	err := &commandError{
+		Endpoint: "https://test.kusto.windows.net",
+		Database: "test-db",
+		Command:  redactCommand(".create external table T (a:string) kind=blob dataformat=csv ('https://a.blob.core.windows.net/c;secretkey')"),
+		Err:      fmt.Errorf("connection reset with query \".create external table T ('https://a.blob.core.windows.net/c;secretkey')\""),
+	}
+
+	diags := commandDiagnostics(nil, err, "error creating Table %q", "T")
+	if strings.Contains(diags[0].Summary, "secretkey") || strings.Contains(diags[0].Detail, "secretkey") {
+		t.Fatalf("expected the storage key to be redacted, got:\n%s\n%s", diags[0].Summary, diags[0].Detail)
+	}
+	if diags[0].AttributePath != nil {
+		t.Fatalf("expected no attribute path, got: %#v", diags[0].AttributePath)
+	}
+
+	if got := fmt.Sprintf("%+v", err); strings.Contains(got, "secretkey") {
+		t.Fatalf("expected the storage key to be redacted from the error, got: %s", got)
+	}
+	if got := diag.FromErr(err)[0].Summary; strings.Contains(got, "secretkey") {
+		t.Fatalf("expected the storage key to be redacted from the error, got: %s", got)
+	}
+}
+
+func TestCommandDiagnostics_otherErrors(t *testing.T) {
+	diags := commandDiagnostics(nil, fmt.Errorf("boom"), "error reading Table %q", "T")
+	if len(diags) != 1 || diags[0].Summary != `error reading Table "T": boom` || diags[0].Detail != "" {
+		t.Fatalf("expected the error to be reported as is, got: %+v", diags)
+	}
+}
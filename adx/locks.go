@@ -0,0 +1,63 @@
+package adx
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// keyedMutex hands out one lock per key, e.g. per cluster and database. Unlike sync.Mutex, waiting for a
+// lock is abandoned when the context is done.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: map[string]chan struct{}{}}
+}
+
+// TryLock acquires the lock for key if it is free, returning its unlock function.
+func (k *keyedMutex) TryLock(key string) (func(), bool) {
+	lock := k.lock(key)
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, true
+	default:
+		return nil, false
+	}
+}
+
+// Lock waits for the lock for key and returns its unlock function, or the error of ctx if it is done first.
+func (k *keyedMutex) Lock(ctx context.Context, key string) (func(), error) {
+	lock := k.lock(key)
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (k *keyedMutex) lock(key string) chan struct{} {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		k.locks[key] = lock
+	}
+	return lock
+}
+
+// databaseLockKey identifies a database across clusters. Database names are case-insensitive.
+func databaseLockKey(endpoint string, db string) string {
+	return endpoint + "|" + strings.ToLower(db)
+}
+
+// isReadOnlyCommand reports whether a management command only reads metadata, in which case it doesn't
+// need to be serialized with other commands on the database.
+func isReadOnlyCommand(command string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(command)), ".show ")
+}
@@ -0,0 +1,102 @@
+package adx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	locks := newKeyedMutex()
+
+	unlock, err := locks.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, ok := locks.TryLock("a"); ok {
+		t.Fatalf("expected the lock for a to be held")
+	}
+	unlockB, ok := locks.TryLock("b")
+	if !ok {
+		t.Fatalf("expected the lock for b to be independent of a")
+	}
+	unlockB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locks.Lock(ctx, "a"); err != context.DeadlineExceeded {
+		t.Fatalf("expected waiting for the lock to end with the context, got: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := locks.Lock(context.Background(), "a")
+		if err == nil {
+			unlock()
+		}
+		close(acquired)
+	}()
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the lock to be acquired once released")
+	}
+}
+
+func TestIsReadOnlyCommand(t *testing.T) {
+	cases := map[string]bool{
+		".show table T cslschema":           true,
+		"  .SHOW database D schema as json": true,
+		".create table T (a:string)":        false,
+		".drop table T ifexists":            false,
+		".showtime":                         false,
+	}
+
+	for command, expected := range cases {
+		if got := isReadOnlyCommand(command); got != expected {
+			t.Errorf("%q: expected %t, got %t", command, expected, got)
+		}
+	}
+}
+
+func TestKustoClient_MgmtSerializesDatabaseChanges(t *testing.T) {
+	server := testKustoServer(t, func(r *http.Request) {})
+	defer server.Close()
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	unlock, err := meta.databaseLocks.Lock(context.Background(), databaseLockKey(server.URL, "Test-DB"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer unlock()
+
+	resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		t.Fatalf("expected reads not to wait for metadata changes, got: %s", err)
+	}
+	resp.Stop()
+
+	if _, err := client.Mgmt(context.Background(), "other-db", kusto.NewStmt(".create table T (a:string)")); err != nil {
+		t.Fatalf("expected changes to other databases not to wait, got: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Mgmt(ctx, "test-db", kusto.NewStmt(".create table T (a:string)")); err == nil {
+		t.Fatalf("expected the change to wait for the database lock")
+	}
+}
@@ -0,0 +1,102 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+// operationPollInterval is how often the state of an async operation is checked.
+var operationPollInterval = 5 * time.Second
+
+// cancelOperationTimeout bounds the `.cancel operation` sent when waiting for an operation is aborted.
+const cancelOperationTimeout = 1 * time.Minute
+
+// MgmtAsync runs an async management command, e.g. `.create async materialized-view`, and waits for the
+// operation it starts to complete. If ctx is done first, because the resource timed out or Terraform was
+// interrupted, the operation is cancelled on the cluster on a best-effort basis.
+func (c *kustoClient) MgmtAsync(ctx context.Context, db string, query kusto.Stmt, options ...kusto.MgmtOption) error {
+	ctx = c.stoppable(ctx)
+
+	resp, err := c.Mgmt(ctx, db, query, options...)
+	if err != nil {
+		return err
+	}
+
+	columns, err := readFirstRow(resp)
+	if err != nil {
+		return err
+	}
+	operationID := columns["OperationId"]
+	if operationID == "" {
+		return fmt.Errorf("async command did not return an OperationId")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.cancelOperation(db, operationID)
+			return fmt.Errorf("waiting for operation %s: %+v", operationID, ctx.Err())
+		case <-time.After(operationPollInterval):
+		}
+
+		state, status, err := c.operationState(ctx, db, operationID)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.cancelOperation(db, operationID)
+			}
+			return fmt.Errorf("reading state of operation %s: %+v", operationID, err)
+		}
+
+		switch state {
+		case "Completed":
+			return nil
+		case "InProgress", "Scheduled", "Throttled":
+			log.Printf("[DEBUG] Operation %s on %s (Database %q) is %s", operationID, c.endpoint, db, state)
+		default:
+			return fmt.Errorf("operation %s %s: %s", operationID, state, status)
+		}
+	}
+}
+
+func (c *kustoClient) operationState(ctx context.Context, db string, operationID string) (string, string, error) {
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	showStatement := fmt.Sprintf(".show operations %s", operationID)
+
+	resp, err := c.Mgmt(ctx, db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(showStatement))
+	if err != nil {
+		return "", "", err
+	}
+
+	columns, err := readFirstRow(resp)
+	if err != nil {
+		return "", "", err
+	}
+	if columns["State"] == "" {
+		return "", "", fmt.Errorf("operation not found")
+	}
+	return columns["State"], columns["Status"], nil
+}
+
+// cancelOperation asks the cluster to cancel an operation. It runs on its own context since the caller's
+// is already done, and failures are only logged as not every operation can be cancelled.
+func (c *kustoClient) cancelOperation(db string, operationID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelOperationTimeout)
+	defer cancel()
+
+	log.Printf("[DEBUG] Cancelling operation %s on %s (Database %q)", operationID, c.endpoint, db)
+
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	cancelStatement := fmt.Sprintf(".cancel operation %s", operationID)
+
+	resp, err := c.mgmt(ctx, db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(cancelStatement))
+	if err != nil {
+		log.Printf("[WARN] Unable to cancel operation %s on %s (Database %q): %+v", operationID, c.endpoint, db, err)
+		return
+	}
+	resp.Stop()
+}
@@ -0,0 +1,128 @@
+package adx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+)
+
+// testOperationsServer answers async commands with an operation ID and reports the operation as state
+// for every `.show operations`. It records the commands it receives.
+func testOperationsServer(t *testing.T, state func() string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var commands []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CSL string `json:"csl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %+v", err)
+		}
+		mu.Lock()
+		commands = append(commands, body.CSL)
+		mu.Unlock()
+
+		columns, row := `{"ColumnName":"Result","DataType":"String"}`, ``
+		switch {
+		case strings.Contains(body.CSL, " async "):
+			columns, row = `{"ColumnName":"OperationId","DataType":"String"}`, `["00000000-0000-0000-0000-000000000001"]`
+		case strings.HasPrefix(body.CSL, ".show operations"):
+			columns = `{"ColumnName":"State","DataType":"String"},{"ColumnName":"Status","DataType":"String"}`
+			row = fmt.Sprintf(`[%q,"details"]`, state())
+		}
+		fmt.Fprintf(w, `{"Tables":[{"TableName":"Table_0","Columns":[%s],"Rows":[%s]}]}`, columns, row)
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), commands...)
+	}
+}
+
+func TestKustoClient_MgmtAsync(t *testing.T) {
+	defer func(interval time.Duration) { operationPollInterval = interval }(operationPollInterval)
+	operationPollInterval = time.Millisecond
+
+	var polls int
+	server, commands := testOperationsServer(t, func() string {
+		polls++
+		if polls < 3 {
+			return "InProgress"
+		}
+		return "Completed"
+	})
+	defer server.Close()
+
+	client := testClient(t, &Config{Endpoint: server.URL}, context.Background())
+	if err := client.MgmtAsync(context.Background(), "test-db", kusto.NewStmt(".create async materialized-view MV on table T { T }")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := commands(); len(got) != 4 || got[3] != ".show operations 00000000-0000-0000-0000-000000000001" {
+		t.Fatalf("expected the operation to be polled until completed, got commands: %q", got)
+	}
+}
+
+func TestKustoClient_MgmtAsyncFailed(t *testing.T) {
+	defer func(interval time.Duration) { operationPollInterval = interval }(operationPollInterval)
+	operationPollInterval = time.Millisecond
+
+	server, _ := testOperationsServer(t, func() string { return "Failed" })
+	defer server.Close()
+
+	client := testClient(t, &Config{Endpoint: server.URL}, context.Background())
+	err := client.MgmtAsync(context.Background(), "test-db", kusto.NewStmt(".create async materialized-view MV on table T { T }"))
+	if err == nil || !strings.Contains(err.Error(), "Failed: details") {
+		t.Fatalf("expected the operation's failure to be reported, got: %v", err)
+	}
+}
+
+func TestKustoClient_MgmtAsyncCancelsOperation(t *testing.T) {
+	defer func(interval time.Duration) { operationPollInterval = interval }(operationPollInterval)
+	operationPollInterval = time.Millisecond
+
+	server, commands := testOperationsServer(t, func() string { return "InProgress" })
+	defer server.Close()
+
+	stop, interrupt := context.WithCancel(context.Background())
+	client := testClient(t, &Config{Endpoint: server.URL}, stop)
+
+	time.AfterFunc(50*time.Millisecond, interrupt)
+
+	err := client.MgmtAsync(context.Background(), "test-db", kusto.NewStmt(".create async materialized-view MV on table T { T }"))
+	if err == nil {
+		t.Fatalf("expected an error when Terraform is interrupted")
+	}
+
+	got := commands()
+	if last := got[len(got)-1]; last != ".cancel operation 00000000-0000-0000-0000-000000000001" {
+		t.Fatalf("expected the operation to be cancelled, got commands: %q", got)
+	}
+}
+
+func TestKustoClient_ExecuteAsync(t *testing.T) {
+	defer func(interval time.Duration) { operationPollInterval = interval }(operationPollInterval)
+	operationPollInterval = time.Millisecond
+
+	server, commands := testOperationsServer(t, func() string { return "Completed" })
+	defer server.Close()
+
+	client := testClient(t, &Config{Endpoint: server.URL}, context.Background())
+	if err := client.Execute(context.Background(), "test-db", kusto.NewStmt(".create async materialized-view MV on table T { T }")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := commands(); len(got) != 2 || got[1] != ".show operations 00000000-0000-0000-0000-000000000001" {
+		t.Fatalf("expected Execute to wait for the async operation, got commands: %q", got)
+	}
+}
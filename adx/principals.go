@@ -0,0 +1,90 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+type Principal struct {
+	Role                 string
+	PrincipalType        string
+	PrincipalDisplayName string
+	PrincipalObjectId    string
+	PrincipalFQN         string
+	Notes                string
+}
+
+// readPrincipals runs `.show <scope> principals` and returns every row. scope is the entity the principals
+// are attached to, e.g. "table MyTable".
+func readPrincipals(ctx context.Context, client *kustoClient, databaseName string, scope string) ([]Principal, error) {
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	showStatement := fmt.Sprintf(".show %s principals", scope)
+
+	resp, err := client.Mgmt(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(showStatement))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Stop()
+
+	var principals []Principal
+	err = resp.Do(
+		func(row *table.Row) error {
+			rec := Principal{}
+			if err := row.ToStruct(&rec); err != nil {
+				return fmt.Errorf("error parsing principals for %s: %+v", scope, err)
+			}
+			principals = append(principals, rec)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return principals, nil
+}
+
+// findPrincipal returns the principal matching role and fqn on the given scope. Kusto reports roles in their
+// display form, prefixed with the scope they are granted on (e.g. "Table db.T Admin" for the `admins` role of
+// a table), and also lists the roles inherited from the database (e.g. "Database db Admin"). Roles are
+// therefore compared on their normalized scope prefix and singular suffix. Cluster roles have no prefix.
+func findPrincipal(principals []Principal, scope string, role string, fqn string) *Principal {
+	wantScope := normalizePrincipalRole(scope)
+	wantRole := strings.TrimSuffix(normalizePrincipalRole(role), "s")
+
+	for i, p := range principals {
+		if !strings.EqualFold(p.PrincipalFQN, fqn) {
+			continue
+		}
+		gotRole := normalizePrincipalRole(p.Role)
+		if strings.HasPrefix(gotRole, wantScope) && strings.HasSuffix(gotRole, wantRole) {
+			return &principals[i]
+		}
+	}
+	return nil
+}
+
+func normalizePrincipalRole(role string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(role))
+}
+
+func principalCommandSuffix(fqn string, notes string) string {
+	if len(notes) != 0 {
+		return fmt.Sprintf("(%s) %s", kqlString(fqn), kqlString(notes))
+	}
+	return fmt.Sprintf("(%s)", kqlString(fqn))
+}
+
+// kqlStringEscaper escapes the characters which can't appear as-is in a single-quoted KQL string literal.
+var kqlStringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// kqlString returns s as a single-quoted KQL string literal, so that values such as notes can't end the
+// literal early and change the command.
+func kqlString(s string) string {
+	return "'" + kqlStringEscaper.Replace(s) + "'"
+}
@@ -0,0 +1,84 @@
+package adx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestPrincipalCommandSuffix(t *testing.T) {
+	cases := []struct {
+		fqn      string
+		notes    string
+		expected string
+	}{
+		{"aadapp=app;tenant", "", `('aadapp=app;tenant')`},
+		{"aaduser=user@contoso.com", "Owner's account", `('aaduser=user@contoso.com') 'Owner\'s account'`},
+		{"aaduser=user@contoso.com", "a\\b\nc", `('aaduser=user@contoso.com') 'a\\b\nc'`},
+		{"aaduser=x'); .drop table T ('", "", `('aaduser=x\'); .drop table T (\'')`},
+	}
+
+	for _, c := range cases {
+		if got := principalCommandSuffix(c.fqn, c.notes); got != c.expected {
+			t.Errorf("%q, %q: expected %s, got %s", c.fqn, c.notes, c.expected, got)
+		}
+	}
+}
+
+func TestFindPrincipal(t *testing.T) {
+	principals := []Principal{
+		{Role: "Database test-db Admin", PrincipalFQN: "aaduser=admin@contoso.com"},
+		{Role: "Table test-db.T Ingestor", PrincipalFQN: "aadapp=app;tenant"},
+	}
+
+	if p := findPrincipal(principals, "Table", "admins", "aaduser=admin@contoso.com"); p != nil {
+		t.Errorf("expected the inherited database admin not to match the table admins, got: %+v", p)
+	}
+	if p := findPrincipal(principals, "Table", "ingestors", "AADAPP=app;tenant"); p == nil || p.Role != "Table test-db.T Ingestor" {
+		t.Errorf("expected the table ingestor to match, got: %+v", p)
+	}
+	if p := findPrincipal(principals, "Materialized View", "admins", "aaduser=admin@contoso.com"); p != nil {
+		t.Errorf("expected the inherited database admin not to match the materialized view admins, got: %+v", p)
+	}
+	if p := findPrincipal([]Principal{{Role: "AllDatabasesAdmin", PrincipalFQN: "aadapp=app;tenant"}}, "", "AllDatabasesAdmin", "aadapp=app;tenant"); p == nil {
+		t.Errorf("expected the cluster role to match")
+	}
+}
+
+func TestResourceADXTablePrincipalRead_tableDropped(t *testing.T) {
+	server, _ := testSchemaServer(t)
+	defer server.Close()
+	// Kusto fails `.show table principals` for a table which doesn't exist
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "principals") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"code":"BadRequest_EntityNotFound","message":"Table 'Dropped' was not found."}}`)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	})
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceADXTablePrincipal().Schema, map[string]interface{}{})
+	d.SetId(formatResourceID(server.URL, "test-db", "Dropped", "admins", "aadapp=app;tenant"))
+
+	if diags := resourceADXTablePrincipalRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("expected no error for a dropped table, got: %+v", diags)
+	}
+	if d.Id() != "" {
+		t.Fatalf("expected the principal of a dropped table to be removed from state")
+	}
+}
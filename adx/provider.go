@@ -2,6 +2,7 @@ package adx
 
 import (
 	"context"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -12,10 +13,53 @@ const TerraformProviderUserAgent = "terraform-provider-adx"
 func Provider() *schema.Provider {
 	p := &schema.Provider{
 		Schema: map[string]*schema.Schema{
+			"connection_string": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_CONNECTION_STRING"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"auth_mode": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_AUTH_MODE"}, authModeAuto),
+				ValidateDiagFunc: stringInSlice([]string{
+					authModeAuto,
+					authModeNone,
+				}),
+			},
+
+			"environment": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: environmentDefault,
+				ValidateDiagFunc: stringInSlice([]string{
+					"public",
+					"usgovernment",
+					"china",
+					"custom",
+				}),
+			},
+
+			"authority_host": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_AUTHORITY_HOST", "AZURE_AUTHORITY_HOST"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"token_audience": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_TOKEN_AUDIENCE"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
 			"client_id": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_CLIENT_ID"}, nil),
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
@@ -23,31 +67,217 @@ func Provider() *schema.Provider {
 				Type:             schema.TypeString,
 				Optional:         true,
 				Sensitive:        true,
-				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_CLIENT_SECRET"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"client_certificate_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_CLIENT_CERTIFICATE_PATH"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"client_certificate": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_CLIENT_CERTIFICATE"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"client_certificate_password": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_CLIENT_CERTIFICATE_PASSWORD"}, nil),
+			},
+
+			"use_oidc": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_USE_OIDC"}, false),
+			},
+
+			"oidc_token_file_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_OIDC_TOKEN_FILE_PATH", "AZURE_FEDERATED_TOKEN_FILE"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"oidc_request_url": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"oidc_request_token": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"oidc_token_endpoint": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_OIDC_TOKEN_ENDPOINT"}, nil),
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
 			"adx_endpoint": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_ENDPOINT"}, nil),
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
 			"tenant_id": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_TENANT_ID"}, nil),
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
+
+			"access_token": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"token_command": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MinItems: 1,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateDiagFunc: stringIsNotEmpty,
+				},
+			},
+
+			"use_msi": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_USE_MSI"}, false),
+			},
+
+			"msi_client_id": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_MSI_CLIENT_ID"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"msi_endpoint": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_MSI_ENDPOINT"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"use_cli": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_USE_CLI"}, false),
+			},
+
+			"cli_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_CLI_PATH"}, nil),
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"max_retries": {
+				Type:             schema.TypeInt,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_MAX_RETRIES"}, 3),
+				ValidateDiagFunc: intAtLeast(0),
+			},
+
+			"retry_max_wait": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_RETRY_MAX_WAIT"}, "30s"),
+				ValidateDiagFunc: stringIsDuration,
+			},
+
+			"max_concurrent_commands": {
+				Type:             schema.TypeInt,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_MAX_CONCURRENT_COMMANDS"}, defaultMaxConcurrentCommands),
+				ValidateDiagFunc: intAtLeast(1),
+			},
+
+			"batch_commands": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_BATCH_COMMANDS"}, false),
+			},
+
+			"batch_window": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.MultiEnvDefaultFunc([]string{"ADX_BATCH_WINDOW"}, "500ms"),
+				ValidateDiagFunc: stringIsDuration,
+			},
+
+			"command_log_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_COMMAND_LOG_PATH"}, ""),
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"proxy_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_PROXY_URL"}, ""),
+			},
+
+			"ca_certificate_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_CA_CERTIFICATE_PATH"}, ""),
+			},
+
+			"ca_certificate": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_CA_CERTIFICATE"}, ""),
+			},
+
+			"tls_min_version": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"ADX_TLS_MIN_VERSION"}, nil),
+				ValidateDiagFunc: stringInSlice([]string{
+					"1.0",
+					"1.1",
+					"1.2",
+					"1.3",
+				}),
+			},
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"adx_table": resourceADXTable(),
-			"adx_table_mapping":       resourceADXTableMapping(),
+			"adx_table":                       resourceADXTable(),
+			"adx_table_mapping":               resourceADXTableMapping(),
+			"adx_table_principal":             resourceADXTablePrincipal(),
+			"adx_cluster_principal":           resourceADXClusterPrincipal(),
+			"adx_function_principal":          resourceADXFunctionPrincipal(),
+			"adx_materialized_view_principal": resourceADXMaterializedViewPrincipal(),
 		},
 	}
 
@@ -59,14 +289,73 @@ func Provider() *schema.Provider {
 func providerConfigure(p *schema.Provider) schema.ConfigureContextFunc {
 	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 		config := &Config{
+			AuthMode: d.Get("auth_mode").(string),
+
+			Environment:   d.Get("environment").(string),
+			AuthorityHost: d.Get("authority_host").(string),
+			TokenAudience: d.Get("token_audience").(string),
+
 			ClientID:     d.Get("client_id").(string),
 			ClientSecret: d.Get("client_secret").(string),
 			TenantID:     d.Get("tenant_id").(string),
 			Endpoint:     d.Get("adx_endpoint").(string),
+			AccessToken:  d.Get("access_token").(string),
+			TokenCommand: expandStringList(d.Get("token_command").([]interface{})),
+			UseMSI:       d.Get("use_msi").(bool),
+			MSIClientID:  d.Get("msi_client_id").(string),
+			MSIEndpoint:  d.Get("msi_endpoint").(string),
+			UseCLI:       d.Get("use_cli").(bool),
+			CLIPath:      d.Get("cli_path").(string),
+
+			ClientCertificatePath:     d.Get("client_certificate_path").(string),
+			ClientCertificate:         d.Get("client_certificate").(string),
+			ClientCertificatePassword: d.Get("client_certificate_password").(string),
+
+			UseOIDC:           d.Get("use_oidc").(bool),
+			OIDCTokenFilePath: d.Get("oidc_token_file_path").(string),
+			OIDCRequestURL:    d.Get("oidc_request_url").(string),
+			OIDCRequestToken:  d.Get("oidc_request_token").(string),
+			OIDCTokenEndpoint: d.Get("oidc_token_endpoint").(string),
+
+			MaxRetries: d.Get("max_retries").(int),
+
+			MaxConcurrentCommands: d.Get("max_concurrent_commands").(int),
+
+			BatchCommands: d.Get("batch_commands").(bool),
+
+			CommandLogPath: d.Get("command_log_path").(string),
+
+			ClientRequestProperties: expandStringMap(d.Get("client_request_properties").(map[string]interface{})),
+
+			ProxyURL:          d.Get("proxy_url").(string),
+			CACertificatePath: d.Get("ca_certificate_path").(string),
+			CACertificate:     d.Get("ca_certificate").(string),
+			TLSMinVersion:     d.Get("tls_min_version").(string),
+		}
+
+		// Already validated by the schema
+		config.RetryMaxWait, _ = time.ParseDuration(d.Get("retry_max_wait").(string))
+		config.BatchWindow, _ = time.ParseDuration(d.Get("batch_window").(string))
+
+		// An explicit connection string takes precedence over the environment, whose endpoint and
+		// credentials would otherwise be mixed with those from the connection string
+		if v, ok := d.GetOk("connection_string"); ok {
+			if err := config.applyConnectionString(v.(string)); err != nil {
+				return nil, diag.FromErr(err)
+			}
+		} else {
+			config.applyEnvironment()
+		}
+
+		// The configure request's own context ends with the request, so resources use the provider's
+		// stop context to notice when Terraform is interrupted
+		stopCtx, ok := schema.StopContext(ctx)
+		if !ok {
+			stopCtx = context.Background()
 		}
 
 		ua := p.UserAgent(TerraformProviderUserAgent, p.TerraformVersion)
 
-		return config.Client(ua)
+		return config.Client(stopCtx, ua)
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 var testAccProviders map[string]*schema.Provider
@@ -23,6 +24,15 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+func TestProvider_validateMinimalConfig(t *testing.T) {
+	diags := Provider().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{
+		"adx_endpoint": "https://test.kusto.windows.net",
+	}))
+	if diags.HasError() {
+		t.Fatalf("expected a minimal configuration to be valid, got: %+v", diags)
+	}
+}
+
 func TestProvider_impl(t *testing.T) {
 	var _ *schema.Provider = Provider()
 }
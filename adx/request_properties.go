@@ -0,0 +1,109 @@
+package adx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+type clientRequestPropertiesKey struct{}
+
+// withClientRequestProperties returns a context carrying the resource's client_request_properties, which
+// override the provider's for every command run with the context.
+func withClientRequestProperties(ctx context.Context, d *schema.ResourceData) context.Context {
+	v, ok := d.GetOk("client_request_properties")
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, clientRequestPropertiesKey{}, expandStringMap(v.(map[string]interface{})))
+}
+
+// updateClientRequestProperties returns the update of a resource whose only attribute changing in place is
+// client_request_properties. The new properties only apply to the next command, so there is nothing to run
+// and the resource is refreshed with read.
+func updateClientRequestProperties(read schema.ReadContextFunc) schema.UpdateContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		return read(ctx, d, meta)
+	}
+}
+
+// clientRequestProperties merges the resource's properties from ctx over the provider's.
+func clientRequestProperties(ctx context.Context, provider map[string]string) map[string]string {
+	resource, _ := ctx.Value(clientRequestPropertiesKey{}).(map[string]string)
+	if len(provider) == 0 && len(resource) == 0 {
+		return nil
+	}
+
+	properties := make(map[string]string, len(provider)+len(resource))
+	for k, v := range provider {
+		properties[k] = v
+	}
+	for k, v := range resource {
+		properties[k] = v
+	}
+	return properties
+}
+
+// clientRequestPropertyValue converts a property from its string form in the configuration into the JSON
+// type Kusto expects, e.g. `true` for norequesttimeout. Timespans such as servertimeout are sent as strings.
+func clientRequestPropertyValue(v string) interface{} {
+	if strings.EqualFold(v, "true") || strings.EqualFold(v, "false") {
+		return strings.EqualFold(v, "true")
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i
+	}
+	return v
+}
+
+// setClientRequestProperties adds properties to the options of the request body built by the SDK, replacing
+// options the SDK set itself, such as the servertimeout derived from the context deadline. The SDK has no
+// MgmtOption for arbitrary properties, so the body is rewritten before the request is sent.
+func setClientRequestProperties(r *http.Request, properties map[string]string) error {
+	if len(properties) == 0 || r.Body == nil {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading request body: %+v", err)
+	}
+	r.Body.Close()
+
+	var msg map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&msg); err != nil {
+		return fmt.Errorf("error decoding request body: %+v", err)
+	}
+
+	requestProperties, _ := msg["properties"].(map[string]interface{})
+	if requestProperties == nil {
+		requestProperties = map[string]interface{}{}
+		msg["properties"] = requestProperties
+	}
+	options, _ := requestProperties["Options"].(map[string]interface{})
+	if options == nil {
+		options = map[string]interface{}{}
+		requestProperties["Options"] = options
+	}
+	for k, v := range properties {
+		options[k] = clientRequestPropertyValue(v)
+	}
+
+	if b, err = json.Marshal(msg); err != nil {
+		return fmt.Errorf("error encoding request body: %+v", err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+
+	return nil
+}
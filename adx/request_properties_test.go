@@ -0,0 +1,82 @@
+package adx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestKustoClient_clientRequestProperties(t *testing.T) {
+	var body struct {
+		Properties struct {
+			Options map[string]interface{}
+		} `json:"properties"`
+	}
+	server := testKustoServer(t, func(r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %+v", err)
+		}
+	})
+	defer server.Close()
+
+	config := &Config{
+		AuthMode: authModeNone,
+		Endpoint: server.URL,
+		ClientRequestProperties: map[string]string{
+			"servertimeout":     "00:45:00",
+			"query_consistency": "weakconsistency",
+		},
+	}
+	meta, diags := config.Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceADXTable().Schema, map[string]interface{}{
+		"client_request_properties": map[string]interface{}{
+			"query_consistency": "strongconsistency",
+			"norequesttimeout":  "true",
+			"maxmemory":         "1024",
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	resp, err := client.Mgmt(withClientRequestProperties(ctx, d), "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Stop()
+
+	expected := map[string]interface{}{
+		"servertimeout":     "00:45:00",
+		"query_consistency": "strongconsistency",
+		"norequesttimeout":  true,
+		"maxmemory":         float64(1024),
+	}
+	for k, v := range expected {
+		if got := body.Properties.Options[k]; got != v {
+			t.Errorf("expected option %q to be %#v, got %#v", k, v, got)
+		}
+	}
+
+	resp, err = client.Mgmt(ctx, "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Stop()
+
+	if got := body.Properties.Options["query_consistency"]; got != "weakconsistency" {
+		t.Errorf("expected the provider's query_consistency without resource overrides, got %#v", got)
+	}
+}
@@ -0,0 +1,178 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceADXClusterPrincipal() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceADXClusterPrincipalCreate,
+		ReadContext:   resourceADXClusterPrincipalRead,
+		UpdateContext: updateClientRequestProperties(resourceADXClusterPrincipalRead),
+		DeleteContext: resourceADXClusterPrincipalDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+				DiffSuppressFunc: suppressEndpointDiff,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"role": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				ValidateDiagFunc: stringInSlice([]string{
+					"AllDatabasesAdmin",
+					"AllDatabasesViewer",
+					"AllDatabasesMonitor",
+				}),
+			},
+
+			"fqn": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"notes": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"principal_type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"principal_display_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"principal_object_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceADXClusterPrincipalCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ctx = withClientRequestProperties(ctx, d)
+	endpoint := meta.(*Meta).ClusterEndpoint(d.Get("cluster_uri").(string))
+	client, err := meta.(*Meta).Client(endpoint)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	role := d.Get("role").(string)
+	fqn := d.Get("fqn").(string)
+
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	addStatement := fmt.Sprintf(".add cluster %s %s", role, principalCommandSuffix(fqn, d.Get("notes").(string)))
+
+	// Cluster-level commands are not scoped to a database
+	err = client.Execute(ctx, "", kusto.NewStmt("", kStmtOpts).UnsafeAdd(addStatement))
+	if err != nil {
+		return commandDiagnostics(d, err, "error adding Cluster Principal %q as %s", fqn, role)
+	}
+
+	id := formatResourceID(endpoint, role, fqn)
+	d.SetId(id)
+
+	resourceADXClusterPrincipalRead(ctx, d, meta)
+
+	return diags
+}
+
+func resourceADXClusterPrincipalRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ctx = withClientRequestProperties(ctx, d)
+
+	id, err := parseADXClusterPrincipalID(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	client, err := meta.(*Meta).Client(id.EndpointURI)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	principals, err := readPrincipals(ctx, client, "", "cluster")
+	if err != nil {
+		return commandDiagnostics(d, err, "error reading Cluster Principals")
+	}
+
+	principal := findPrincipal(principals, "", id.Role, id.FQN)
+	if principal == nil {
+		d.SetId("")
+		return diags
+	}
+
+	d.Set("cluster_uri", id.EndpointURI)
+	d.Set("role", id.Role)
+	d.Set("fqn", id.FQN)
+	d.Set("notes", principal.Notes)
+	d.Set("principal_type", principal.PrincipalType)
+	d.Set("principal_display_name", principal.PrincipalDisplayName)
+	d.Set("principal_object_id", principal.PrincipalObjectId)
+
+	return diags
+}
+
+func resourceADXClusterPrincipalDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ctx = withClientRequestProperties(ctx, d)
+
+	id, err := parseADXClusterPrincipalID(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	client, err := meta.(*Meta).Client(id.EndpointURI)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	dropStatement := fmt.Sprintf(".drop cluster %s %s", id.Role, principalCommandSuffix(id.FQN, ""))
+
+	err = client.Execute(ctx, "", kusto.NewStmt("", kStmtOpts).UnsafeAdd(dropStatement))
+	if err != nil {
+		return commandDiagnostics(d, err, "error dropping Cluster Principal %q as %s", id.FQN, id.Role)
+	}
+
+	d.SetId("")
+
+	return diags
+}
@@ -0,0 +1,240 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// principalEntity describes a database entity which carries its own principals, such as a stored function
+// or a materialized view.
+type principalEntity struct {
+	// Kind is the entity keyword used in management commands, e.g. "materialized-view".
+	Kind string
+	// DisplayName is used in error messages, e.g. "Materialized View".
+	DisplayName string
+	// NameAttribute is the schema attribute holding the entity name, e.g. "materialized_view_name".
+	NameAttribute string
+	Roles         []string
+}
+
+var functionPrincipalEntity = principalEntity{
+	Kind:          "function",
+	DisplayName:   "Function",
+	NameAttribute: "function_name",
+	Roles:         []string{"admins"},
+}
+
+var materializedViewPrincipalEntity = principalEntity{
+	Kind:          "materialized-view",
+	DisplayName:   "Materialized View",
+	NameAttribute: "materialized_view_name",
+	Roles:         []string{"admins"},
+}
+
+func resourceADXFunctionPrincipal() *schema.Resource {
+	return resourceADXEntityPrincipal(functionPrincipalEntity)
+}
+
+func resourceADXMaterializedViewPrincipal() *schema.Resource {
+	return resourceADXEntityPrincipal(materializedViewPrincipalEntity)
+}
+
+func resourceADXEntityPrincipal(entity principalEntity) *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceADXEntityPrincipalCreate(entity),
+		ReadContext:   resourceADXEntityPrincipalRead(entity),
+		UpdateContext: updateClientRequestProperties(resourceADXEntityPrincipalRead(entity)),
+		DeleteContext: resourceADXEntityPrincipalDelete(entity),
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+				DiffSuppressFunc: suppressEndpointDiff,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"database_name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			entity.NameAttribute: {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"role": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringInSlice(entity.Roles),
+			},
+
+			"fqn": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"notes": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"principal_type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"principal_display_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"principal_object_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceADXEntityPrincipalCreate(entity principalEntity) schema.CreateContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		var diags diag.Diagnostics
+		ctx = withClientRequestProperties(ctx, d)
+		endpoint := meta.(*Meta).ClusterEndpoint(d.Get("cluster_uri").(string))
+		client, err := meta.(*Meta).Client(endpoint)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		databaseName := d.Get("database_name").(string)
+		entityName := d.Get(entity.NameAttribute).(string)
+		role := d.Get("role").(string)
+		fqn := d.Get("fqn").(string)
+
+		kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+		addStatement := fmt.Sprintf(".add %s %s %s %s", entity.Kind, entityName, role, principalCommandSuffix(fqn, d.Get("notes").(string)))
+
+		err = client.Execute(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(addStatement))
+		if err != nil {
+			return commandDiagnostics(d, err, "error adding Principal %q as %s (%s %q, Database %q)", fqn, role, entity.DisplayName, entityName, databaseName)
+		}
+
+		id := formatResourceID(endpoint, databaseName, entityName, role, fqn)
+		d.SetId(id)
+
+		resourceADXEntityPrincipalRead(entity)(ctx, d, meta)
+
+		return diags
+	}
+}
+
+func resourceADXEntityPrincipalRead(entity principalEntity) schema.ReadContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		var diags diag.Diagnostics
+		ctx = withClientRequestProperties(ctx, d)
+
+		id, err := parseADXEntityPrincipalID(d.Id())
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		client, err := meta.(*Meta).Client(id.EndpointURI)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		databaseSchema, err := client.DatabaseSchema(ctx, id.DatabaseName)
+		if err != nil {
+			return commandDiagnostics(d, err, "error reading %s %q (Database %q)", entity.DisplayName, id.EntityName, id.DatabaseName)
+		}
+		if !databaseSchema.HasEntity(entity.Kind, id.EntityName) {
+			d.SetId("")
+			return diags
+		}
+
+		principals, err := readPrincipals(ctx, client, id.DatabaseName, fmt.Sprintf("%s %s", entity.Kind, id.EntityName))
+		if err != nil {
+			return commandDiagnostics(d, err, "error reading Principals (%s %q, Database %q)", entity.DisplayName, id.EntityName, id.DatabaseName)
+		}
+
+		principal := findPrincipal(principals, entity.DisplayName, id.Role, id.FQN)
+		if principal == nil {
+			d.SetId("")
+			return diags
+		}
+
+		d.Set("cluster_uri", id.EndpointURI)
+		d.Set("database_name", id.DatabaseName)
+		d.Set(entity.NameAttribute, id.EntityName)
+		d.Set("role", id.Role)
+		d.Set("fqn", id.FQN)
+		d.Set("notes", principal.Notes)
+		d.Set("principal_type", principal.PrincipalType)
+		d.Set("principal_display_name", principal.PrincipalDisplayName)
+		d.Set("principal_object_id", principal.PrincipalObjectId)
+
+		return diags
+	}
+}
+
+func resourceADXEntityPrincipalDelete(entity principalEntity) schema.DeleteContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		var diags diag.Diagnostics
+		ctx = withClientRequestProperties(ctx, d)
+
+		id, err := parseADXEntityPrincipalID(d.Id())
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		client, err := meta.(*Meta).Client(id.EndpointURI)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+		dropStatement := fmt.Sprintf(".drop %s %s %s %s", entity.Kind, id.EntityName, id.Role, principalCommandSuffix(id.FQN, ""))
+
+		err = client.Execute(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(dropStatement))
+		if err != nil {
+			return commandDiagnostics(d, err, "error dropping Principal %q as %s (%s %q, Database %q)", id.FQN, id.Role, entity.DisplayName, id.EntityName, id.DatabaseName)
+		}
+
+		d.SetId("")
+
+		return diags
+	}
+}
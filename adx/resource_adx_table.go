@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
-	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/unsafe"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -25,20 +25,54 @@ func resourceADXTable() *schema.Resource {
 	return &schema.Resource{
 		CreateContext: resourceADXTableCreate,
 		ReadContext:   resourceADXTableRead,
+		UpdateContext: updateClientRequestProperties(resourceADXTableRead),
 		DeleteContext: resourceADXTableDelete,
 
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Type:    resourceADXTableV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceADXTableStateUpgradeV0,
+				Version: 0,
+			},
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+				DiffSuppressFunc: suppressEndpointDiff,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"database_name": {
 				Type:             schema.TypeString,
 				Required:         true,
-				ForceNew:         false,
+				ForceNew:         true,
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
 			"name": {
 				Type:             schema.TypeString,
 				Required:         true,
-				ForceNew:         false,
+				ForceNew:         true,
 				ValidateDiagFunc: stringIsNotEmpty,
 			},
 
@@ -83,7 +117,12 @@ func resourceADXTable() *schema.Resource {
 
 func resourceADXTableCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-	client := meta.(*Meta).Kusto
+	ctx = withClientRequestProperties(ctx, d)
+	endpoint := meta.(*Meta).ClusterEndpoint(d.Get("cluster_uri").(string))
+	client, err := meta.(*Meta).Client(endpoint)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	tableName := d.Get("name").(string)
 	databaseName := d.Get("database_name").(string)
@@ -99,12 +138,12 @@ func resourceADXTableCreate(ctx context.Context, d *schema.ResourceData, meta in
 	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
 	createStatement := fmt.Sprintf(".create table %s (%s)", tableName, tableDef)
 
-	_, err := client.Mgmt(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(createStatement))
+	err = client.Execute(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(createStatement))
 	if err != nil {
-		return diag.Errorf("error creating Table %q (Database %q): %+v", tableName, databaseName, err)
+		return commandDiagnostics(d, err, "error creating Table %q (Database %q)", tableName, databaseName)
 	}
 
-	id := fmt.Sprintf("%s|%s|%s", client.Endpoint(), databaseName, tableName)
+	id := formatResourceID(endpoint, databaseName, tableName)
 	d.SetId(id)
 
 	resourceADXTableRead(ctx, d, meta)
@@ -114,63 +153,59 @@ func resourceADXTableCreate(ctx context.Context, d *schema.ResourceData, meta in
 
 func resourceADXTableRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-
-	client := meta.(*Meta).Kusto
+	ctx = withClientRequestProperties(ctx, d)
 
 	id, err := parseADXTableID(d.Id())
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
-	showStatement := fmt.Sprintf(".show table %s cslschema", id.Name)
-
-	resp, err := client.Mgmt(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(showStatement))
+	client, err := meta.(*Meta).Client(id.EndpointURI)
 	if err != nil {
-		return diag.Errorf("error reading Table %q (Database %q): %+v", id.Name, id.DatabaseName, err)
+		return diag.FromErr(err)
 	}
-	defer resp.Stop()
-
-	var schemas []TableSchema
-	err = resp.Do(
-		func(row *table.Row) error {
-			rec := TableSchema{}
-			if err := row.ToStruct(&rec); err != nil {
-				return fmt.Errorf("error parsing Table schema for Table %q (Database %q): %+v", id.Name, id.DatabaseName, err)
-			}
-			schemas = append(schemas, rec)
-			return nil
-		},
-	)
 
+	databaseSchema, err := client.DatabaseSchema(ctx, id.DatabaseName)
 	if err != nil {
-		return diag.Errorf("%+v", err)
+		return commandDiagnostics(d, err, "error reading Table %q (Database %q)", id.Name, id.DatabaseName)
 	}
 
-	d.Set("name", schemas[0].TableName)
-	d.Set("database_name", schemas[0].DatabaseName)
-	d.Set("table_schema", schemas[0].Schema)
-	d.Set("column", flattenTableColumn(schemas[0].Schema))
+	tableSchema, ok := databaseSchema.Table(id.Name)
+	if !ok {
+		d.SetId("")
+		return diags
+	}
+
+	// Keep the name as configured, as Kusto reports quoted names without their quotes
+	d.Set("cluster_uri", id.EndpointURI)
+	d.Set("name", id.Name)
+	d.Set("database_name", tableSchema.DatabaseName)
+	d.Set("table_schema", tableSchema.Schema)
+	d.Set("column", flattenTableColumn(tableSchema.Schema))
 
 	return diags
 }
 
 func resourceADXTableDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-
-	client := meta.(*Meta).Kusto
+	ctx = withClientRequestProperties(ctx, d)
 
 	id, err := parseADXTableID(d.Id())
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	client, err := meta.(*Meta).Client(id.EndpointURI)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
 	deleteStatement := fmt.Sprintf(".drop table %s", id.Name)
 
-	_, err = client.Mgmt(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(deleteStatement))
+	err = client.Execute(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(deleteStatement))
 	if err != nil {
-		return diag.Errorf("error deleting Table %q (Database %q): %+v", id.Name, id.DatabaseName, err)
+		return commandDiagnostics(d, err, "error deleting Table %q (Database %q)", id.Name, id.DatabaseName)
 	}
 
 	d.SetId("")
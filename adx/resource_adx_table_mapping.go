@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
-	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/Azure/azure-kusto-go/kusto/unsafe"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -37,7 +37,40 @@ func resourceADXTableMapping() *schema.Resource {
 		ReadContext:   resourceADXTableMappingRead,
 		DeleteContext: resourceADXTableMappingDelete,
 
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Type:    resourceADXTableMappingV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceADXTableMappingStateUpgradeV0,
+				Version: 0,
+			},
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+				DiffSuppressFunc: suppressEndpointDiff,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"name": {
 				Type:             schema.TypeString,
 				Required:         true,
@@ -102,7 +135,12 @@ func resourceADXTableMapping() *schema.Resource {
 
 func resourceADXTableMappingCreateUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-	client := meta.(*Meta).Kusto
+	ctx = withClientRequestProperties(ctx, d)
+	endpoint := meta.(*Meta).ClusterEndpoint(d.Get("cluster_uri").(string))
+	client, err := meta.(*Meta).Client(endpoint)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	name := d.Get("name").(string)
 	tableName := d.Get("table_name").(string)
@@ -113,12 +151,12 @@ func resourceADXTableMappingCreateUpdate(ctx context.Context, d *schema.Resource
 	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
 	createStatement := fmt.Sprintf(".create-or-alter table %s ingestion %s mapping '%s' '[%s]'", tableName, strings.ToLower(kind), name, mapping)
 
-	_, err := client.Mgmt(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(createStatement))
+	err = client.Execute(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(createStatement))
 	if err != nil {
-		return diag.Errorf("error creating Mapping %q (Table %q, Database %q): %+v", name, tableName, databaseName, err)
+		return commandDiagnostics(d, err, "error creating Mapping %q (Table %q, Database %q)", name, tableName, databaseName)
 	}
 
-	id := fmt.Sprintf("%s|%s|%s|%s|%s", client.Endpoint(), databaseName, tableName, strings.ToLower(kind), name)
+	id := formatResourceID(endpoint, databaseName, tableName, strings.ToLower(kind), name)
 	d.SetId(id)
 
 	resourceADXTableMappingRead(ctx, d, meta)
@@ -128,65 +166,59 @@ func resourceADXTableMappingCreateUpdate(ctx context.Context, d *schema.Resource
 
 func resourceADXTableMappingRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-
-	client := meta.(*Meta).Kusto
+	ctx = withClientRequestProperties(ctx, d)
 
 	id, err := parseADXTableMappingID(d.Id())
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
-	showStatement := fmt.Sprintf(".show table %s ingestion %s mapping '%s'", id.TableName, strings.ToLower(id.Kind), id.Name)
-
-	resp, err := client.Mgmt(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(showStatement))
+	client, err := meta.(*Meta).Client(id.EndpointURI)
 	if err != nil {
-		return diag.Errorf("error reading Table %q (Database %q): %+v", id.Name, id.DatabaseName, err)
+		return diag.FromErr(err)
 	}
-	defer resp.Stop()
-
-	var schemas []TableMapping
-	err = resp.Do(
-		func(row *table.Row) error {
-			rec := TableMapping{}
-			if err := row.ToStruct(&rec); err != nil {
-				return fmt.Errorf("error parsing Table schema for Table %q (Database %q): %+v", id.Name, id.DatabaseName, err)
-			}
-			schemas = append(schemas, rec)
-			return nil
-		},
-	)
 
+	databaseSchema, err := client.DatabaseSchema(ctx, id.DatabaseName)
 	if err != nil {
-		return diag.Errorf("%+v", err)
+		return commandDiagnostics(d, err, "error reading Mapping %q (Table %q, Database %q)", id.Name, id.TableName, id.DatabaseName)
 	}
 
-	d.Set("table_name", schemas[0].Table)
-	d.Set("database_name", schemas[0].Database)
-	d.Set("kind", schemas[0].Kind)
-	d.Set("mapping", flattenTableMapping(schemas[0].Mapping))
-	d.Set("last_updated_on", schemas[0].LastUpdatedOn)
+	mapping := databaseSchema.Mapping(id.TableName, id.Kind, id.Name)
+	if mapping == nil {
+		d.SetId("")
+		return diags
+	}
 
+	d.Set("cluster_uri", id.EndpointURI)
+	d.Set("table_name", id.TableName)
+	d.Set("database_name", mapping.Database)
+	d.Set("kind", mapping.Kind)
+	d.Set("mapping", flattenTableMapping(mapping.Mapping))
+	d.Set("last_updated_on", mapping.LastUpdatedOn)
 
 	return diags
 }
 
 func resourceADXTableMappingDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-
-	client := meta.(*Meta).Kusto
+	ctx = withClientRequestProperties(ctx, d)
 
 	id, err := parseADXTableMappingID(d.Id())
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	client, err := meta.(*Meta).Client(id.EndpointURI)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
 	deleteStatement := fmt.Sprintf(".drop table %s ingestion %s mapping '%s'", id.TableName, strings.ToLower(id.Kind), id.Name)
 
-	_, err = client.Mgmt(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(deleteStatement))
+	err = client.Execute(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(deleteStatement))
 	if err != nil {
-		return diag.Errorf("error deleting Table Mapping %q (Table %q, Database %q): %+v", id.Name, id.TableName, id.DatabaseName, err)
+		return commandDiagnostics(d, err, "error deleting Table Mapping %q (Table %q, Database %q)", id.Name, id.TableName, id.DatabaseName)
 	}
 
 	d.SetId("")
@@ -0,0 +1,99 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceADXTableMappingV0 is the schema of adx_table_mapping before IDs were escaped and endpoints
+// normalized.
+func resourceADXTableMappingV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"database_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"table_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"kind": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"mapping": {
+				Type:     schema.TypeList,
+				Required: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"column": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"path": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"datatype": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"transform": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"last_updated_on": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// resourceADXTableMappingStateUpgradeV0 rewrites IDs of the form
+// `<endpoint>|<database>|<table>|<kind>|<mapping>` with unescaped parts into the escaped format, normalizing
+// the endpoint.
+func resourceADXTableMappingStateUpgradeV0(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	id, _ := rawState["id"].(string)
+	parts := strings.Split(id, "|")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("error parsing ADX Table Mapping resource ID: unexpected format: %q", id)
+	}
+
+	endpoint := normalizeEndpoint(parts[0])
+	rawState["id"] = formatResourceID(endpoint, parts[1], parts[2], strings.ToLower(parts[3]), parts[4])
+	rawState["cluster_uri"] = endpoint
+
+	return rawState, nil
+}
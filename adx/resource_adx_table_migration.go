@@ -0,0 +1,80 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceADXTableV0 is the schema of adx_table before IDs were escaped and endpoints normalized.
+func resourceADXTableV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"database_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"table_schema": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+
+			"column": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// resourceADXTableStateUpgradeV0 rewrites IDs of the form `<endpoint>|<database>|<table>` with unescaped
+// parts into the escaped format, normalizing the endpoint.
+func resourceADXTableStateUpgradeV0(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	id, _ := rawState["id"].(string)
+	parts := strings.Split(id, "|")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("error parsing ADX Table resource ID: unexpected format: %q", id)
+	}
+
+	endpoint := normalizeEndpoint(parts[0])
+	rawState["id"] = formatResourceID(endpoint, parts[1], parts[2])
+	rawState["cluster_uri"] = endpoint
+
+	return rawState, nil
+}
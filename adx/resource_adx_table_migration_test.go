@@ -0,0 +1,49 @@
+package adx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResourceADXTableStateUpgradeV0(t *testing.T) {
+	state, err := resourceADXTableStateUpgradeV0(context.Background(), map[string]interface{}{
+		"id":            "https://Test.kusto.windows.net/|test-db|T%1",
+		"name":          "T%1",
+		"database_name": "test-db",
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if expected := "https://test.kusto.windows.net|test-db|T%251"; state["id"] != expected {
+		t.Fatalf("expected ID %q, got %q", expected, state["id"])
+	}
+	if state["cluster_uri"] != "https://test.kusto.windows.net" {
+		t.Fatalf("expected the endpoint to be normalized, got %q", state["cluster_uri"])
+	}
+
+	id, err := parseADXTableID(state["id"].(string))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id.Name != "T%1" {
+		t.Fatalf("expected the upgraded ID to round-trip, got: %+v", id)
+	}
+}
+
+func TestResourceADXTableMappingStateUpgradeV0(t *testing.T) {
+	state, err := resourceADXTableMappingStateUpgradeV0(context.Background(), map[string]interface{}{
+		"id": "https://test.kusto.windows.net/|test-db|T|Json|M",
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if expected := "https://test.kusto.windows.net|test-db|T|json|M"; state["id"] != expected {
+		t.Fatalf("expected ID %q, got %q", expected, state["id"])
+	}
+
+	if _, err := resourceADXTableMappingStateUpgradeV0(context.Background(), map[string]interface{}{"id": "https://test.kusto.windows.net|test-db|T"}, nil); err == nil {
+		t.Fatalf("expected an error for a malformed ID")
+	}
+}
@@ -0,0 +1,203 @@
+package adx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceADXTablePrincipal() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceADXTablePrincipalCreate,
+		ReadContext:   resourceADXTablePrincipalRead,
+		UpdateContext: updateClientRequestProperties(resourceADXTablePrincipalRead),
+		DeleteContext: resourceADXTablePrincipalDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"cluster_uri": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+				DiffSuppressFunc: suppressEndpointDiff,
+			},
+
+			"client_request_properties": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"database_name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"table_name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"role": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				ValidateDiagFunc: stringInSlice([]string{
+					"admins",
+					"ingestors",
+				}),
+			},
+
+			"fqn": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: stringIsNotEmpty,
+			},
+
+			"notes": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"principal_type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"principal_display_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"principal_object_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceADXTablePrincipalCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ctx = withClientRequestProperties(ctx, d)
+	endpoint := meta.(*Meta).ClusterEndpoint(d.Get("cluster_uri").(string))
+	client, err := meta.(*Meta).Client(endpoint)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	databaseName := d.Get("database_name").(string)
+	tableName := d.Get("table_name").(string)
+	role := d.Get("role").(string)
+	fqn := d.Get("fqn").(string)
+
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	addStatement := fmt.Sprintf(".add table %s %s %s", tableName, role, principalCommandSuffix(fqn, d.Get("notes").(string)))
+
+	err = client.Execute(ctx, databaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(addStatement))
+	if err != nil {
+		return commandDiagnostics(d, err, "error adding Principal %q as %s (Table %q, Database %q)", fqn, role, tableName, databaseName)
+	}
+
+	id := formatResourceID(endpoint, databaseName, tableName, role, fqn)
+	d.SetId(id)
+
+	resourceADXTablePrincipalRead(ctx, d, meta)
+
+	return diags
+}
+
+func resourceADXTablePrincipalRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ctx = withClientRequestProperties(ctx, d)
+
+	id, err := parseADXTablePrincipalID(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	client, err := meta.(*Meta).Client(id.EndpointURI)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	databaseSchema, err := client.DatabaseSchema(ctx, id.DatabaseName)
+	if err != nil {
+		return commandDiagnostics(d, err, "error reading Table %q (Database %q)", id.Name, id.DatabaseName)
+	}
+	if _, ok := databaseSchema.Table(id.Name); !ok {
+		d.SetId("")
+		return diags
+	}
+
+	principals, err := readPrincipals(ctx, client, id.DatabaseName, fmt.Sprintf("table %s", id.Name))
+	if err != nil {
+		return commandDiagnostics(d, err, "error reading Principals (Table %q, Database %q)", id.Name, id.DatabaseName)
+	}
+
+	principal := findPrincipal(principals, "Table", id.Role, id.FQN)
+	if principal == nil {
+		d.SetId("")
+		return diags
+	}
+
+	d.Set("cluster_uri", id.EndpointURI)
+	d.Set("database_name", id.DatabaseName)
+	d.Set("table_name", id.Name)
+	d.Set("role", id.Role)
+	d.Set("fqn", id.FQN)
+	d.Set("notes", principal.Notes)
+	d.Set("principal_type", principal.PrincipalType)
+	d.Set("principal_display_name", principal.PrincipalDisplayName)
+	d.Set("principal_object_id", principal.PrincipalObjectId)
+
+	return diags
+}
+
+func resourceADXTablePrincipalDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	ctx = withClientRequestProperties(ctx, d)
+
+	id, err := parseADXTablePrincipalID(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	client, err := meta.(*Meta).Client(id.EndpointURI)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+	dropStatement := fmt.Sprintf(".drop table %s %s %s", id.Name, id.Role, principalCommandSuffix(id.FQN, ""))
+
+	err = client.Execute(ctx, id.DatabaseName, kusto.NewStmt("", kStmtOpts).UnsafeAdd(dropStatement))
+	if err != nil {
+		return commandDiagnostics(d, err, "error dropping Principal %q as %s (Table %q, Database %q)", id.FQN, id.Role, id.Name, id.DatabaseName)
+	}
+
+	d.SetId("")
+
+	return diags
+}
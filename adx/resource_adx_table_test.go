@@ -0,0 +1,85 @@
+package adx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResourceADXTable_quotedName(t *testing.T) {
+	var created string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CSL string `json:"csl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %+v", err)
+		}
+
+		columns, row := `{"ColumnName":"Result","DataType":"String"}`, ``
+		switch {
+		case strings.HasPrefix(body.CSL, ".create table"):
+			created = body.CSL
+		case strings.HasSuffix(body.CSL, "schema as json"):
+			// Kusto reports the table name without its quotes
+			schemaJSON := `{"Databases":{"test-db":{"Name":"test-db","Tables":{"my-table":{"Name":"my-table",` +
+				`"OrderedColumns":[{"Name":"a","CslType":"string"}]}}}}}`
+			columns, row = `{"ColumnName":"DatabaseSchema","DataType":"String"}`, fmt.Sprintf(`[%q]`, schemaJSON)
+		}
+		fmt.Fprintf(w, `{"Tables":[{"TableName":"Table_0","Columns":[%s],"Rows":[%s]}]}`, columns, row)
+	}))
+	defer server.Close()
+
+	meta, diags := (&Config{AuthMode: authModeNone, Endpoint: server.URL}).Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceADXTable().Schema, map[string]interface{}{
+		"name":          "['my-table']",
+		"database_name": "test-db",
+		"table_schema":  "a:string",
+	})
+	if diags := resourceADXTableCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	if created != ".create table ['my-table'] (a:string)" {
+		t.Fatalf("unexpected create command: %q", created)
+	}
+
+	if diags := resourceADXTableRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	if d.Id() == "" {
+		t.Fatalf("expected the quoted table to be found")
+	}
+	if got := d.Get("name").(string); got != "['my-table']" {
+		t.Fatalf("expected the name to keep its quotes, got %q", got)
+	}
+	if got := d.Get("table_schema").(string); got != "a:string" {
+		t.Fatalf("expected the table schema to be read, got %q", got)
+	}
+}
+
+func TestUnquoteEntityName(t *testing.T) {
+	cases := map[string]string{
+		"T":               "T",
+		"['my-table']":    "my-table",
+		`["my-table"]`:    "my-table",
+		`['it\'s']`:       "it's",
+		"['unterminated]": "['unterminated]",
+		"[\"mismatched']": "[\"mismatched']",
+	}
+
+	for name, expected := range cases {
+		if got := unquoteEntityName(name); got != expected {
+			t.Errorf("%q: expected %q, got %q", name, expected, got)
+		}
+	}
+}
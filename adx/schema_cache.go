@@ -0,0 +1,236 @@
+package adx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+// databaseSchema is the metadata of a database which resources read, fetched with two commands instead of
+// one per resource.
+type databaseSchema struct {
+	Tables            map[string]TableSchema
+	Functions         map[string]bool
+	MaterializedViews map[string]bool
+	Mappings          []TableMapping
+}
+
+// databaseSchemaJSON is the part of the output of `.show database D schema as json` used by the provider.
+type databaseSchemaJSON struct {
+	Databases map[string]struct {
+		Name   string
+		Tables map[string]struct {
+			Name           string
+			Folder         string
+			DocString      string
+			OrderedColumns []struct {
+				Name    string
+				CslType string
+			}
+		}
+		Functions map[string]struct {
+			Name string
+		}
+		MaterializedViews map[string]struct {
+			Name string
+		}
+	}
+}
+
+// Table returns the table with the given name, if it exists. Like the other lookups, it accepts names quoted
+// as in commands, e.g. `['my-table']`.
+func (s *databaseSchema) Table(name string) (TableSchema, bool) {
+	table, ok := s.Tables[unquoteEntityName(name)]
+	return table, ok
+}
+
+// Mapping returns the ingestion mapping of tableName with the given kind and name, if it exists.
+func (s *databaseSchema) Mapping(tableName string, kind string, name string) *TableMapping {
+	tableName = unquoteEntityName(tableName)
+	for i, m := range s.Mappings {
+		if m.Table == tableName && m.Name == name && strings.EqualFold(m.Kind, kind) {
+			return &s.Mappings[i]
+		}
+	}
+	return nil
+}
+
+// HasEntity reports whether the function or materialized view with the given name exists. kind is the
+// entity keyword used in management commands, as in principalEntity.
+func (s *databaseSchema) HasEntity(kind string, name string) bool {
+	name = unquoteEntityName(name)
+	switch kind {
+	case "function":
+		return s.Functions[name]
+	case "materialized-view":
+		return s.MaterializedViews[name]
+	}
+	return false
+}
+
+// schemaCache holds the schema of every database read during a run, so refreshing many resources in a
+// database only fetches its schema once. Entries are invalidated by any command which changes metadata.
+type schemaCache struct {
+	mu      sync.Mutex
+	entries map[string]*schemaCacheEntry
+}
+
+type schemaCacheEntry struct {
+	ready  chan struct{}
+	schema *databaseSchema
+	err    error
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{entries: map[string]*schemaCacheEntry{}}
+}
+
+// get returns the cached schema for key, loading it if there is none. Concurrent callers wait for the same
+// load. Failed loads aren't cached.
+func (c *schemaCache) get(ctx context.Context, key string, load func() (*databaseSchema, error)) (*databaseSchema, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &schemaCacheEntry{ready: make(chan struct{})}
+		c.entries[key] = entry
+		c.mu.Unlock()
+
+		entry.schema, entry.err = load()
+		close(entry.ready)
+
+		if entry.err != nil {
+			c.invalidateEntry(key, entry)
+		}
+		return entry.schema, entry.err
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.schema, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *schemaCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+func (c *schemaCache) invalidateEntry(key string, entry *schemaCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+}
+
+// DatabaseSchema returns the schema of db, which is read once per run unless metadata in db changes.
+func (c *kustoClient) DatabaseSchema(ctx context.Context, db string) (*databaseSchema, error) {
+	return c.schemas.get(ctx, databaseLockKey(c.endpoint, db), func() (*databaseSchema, error) {
+		return c.readDatabaseSchema(ctx, db)
+	})
+}
+
+func (c *kustoClient) readDatabaseSchema(ctx context.Context, db string) (*databaseSchema, error) {
+	kStmtOpts := kusto.UnsafeStmt(unsafe.Stmt{Add: true})
+
+	showStatement := fmt.Sprintf(".show database %s schema as json", db)
+	resp, err := c.Mgmt(ctx, db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(showStatement))
+	if err != nil {
+		return nil, err
+	}
+	columns, err := readFirstRow(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemaJSON databaseSchemaJSON
+	if err := json.Unmarshal([]byte(columns["DatabaseSchema"]), &schemaJSON); err != nil {
+		return nil, fmt.Errorf("error parsing schema of Database %q: %+v", db, err)
+	}
+
+	schema := &databaseSchema{
+		Tables:            map[string]TableSchema{},
+		Functions:         map[string]bool{},
+		MaterializedViews: map[string]bool{},
+	}
+	for _, database := range schemaJSON.Databases {
+		for _, t := range database.Tables {
+			columns := make([]string, 0, len(t.OrderedColumns))
+			for _, column := range t.OrderedColumns {
+				columns = append(columns, fmt.Sprintf("%s:%s", column.Name, column.CslType))
+			}
+			schema.Tables[t.Name] = TableSchema{
+				TableName:    t.Name,
+				Schema:       strings.Join(columns, ","),
+				DatabaseName: database.Name,
+				Folder:       t.Folder,
+				DocString:    t.DocString,
+			}
+		}
+		for _, f := range database.Functions {
+			schema.Functions[f.Name] = true
+		}
+		for _, mv := range database.MaterializedViews {
+			schema.MaterializedViews[mv.Name] = true
+		}
+	}
+
+	showStatement = fmt.Sprintf(".show database %s ingestion mappings", db)
+	resp, err = c.Mgmt(ctx, db, kusto.NewStmt("", kStmtOpts).UnsafeAdd(showStatement))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Stop()
+
+	err = resp.Do(
+		func(row *table.Row) error {
+			rec := TableMapping{}
+			if err := row.ToStruct(&rec); err != nil {
+				return fmt.Errorf("error parsing ingestion mappings of Database %q: %+v", db, err)
+			}
+			schema.Mappings = append(schema.Mappings, rec)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// readFirstRow returns the first row of resp as strings keyed by column name, or an empty map if there are
+// no rows.
+func readFirstRow(resp *kusto.RowIterator) (map[string]string, error) {
+	defer resp.Stop()
+
+	columns := map[string]string{}
+	err := resp.Do(
+		func(row *table.Row) error {
+			if len(columns) != 0 {
+				return nil
+			}
+			for i, column := range row.ColumnTypes {
+				columns[column.Name] = strings.TrimSpace(row.Values[i].String())
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,117 @@
+package adx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+)
+
+const testDatabaseSchemaJSON = `{"Databases":{"test-db":{"Name":"test-db",` +
+	`"Tables":{"T":{"Name":"T","Folder":"f","DocString":"d","OrderedColumns":[{"Name":"a","CslType":"string"},{"Name":"b","CslType":"int"}]}},` +
+	`"Functions":{"F":{"Name":"F"}},"MaterializedViews":{"MV":{"Name":"MV"}}}}}`
+
+// testSchemaServer answers `.show database` commands with a database with one table, function, materialized
+// view and mapping. It counts the schema reads.
+func testSchemaServer(t *testing.T) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	var reads int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CSL string `json:"csl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("error decoding request: %+v", err)
+		}
+
+		columns, row := `{"ColumnName":"Result","DataType":"String"}`, ``
+		switch {
+		case strings.HasSuffix(body.CSL, "schema as json"):
+			mu.Lock()
+			reads++
+			mu.Unlock()
+			columns, row = `{"ColumnName":"DatabaseSchema","DataType":"String"}`, fmt.Sprintf(`[%q]`, testDatabaseSchemaJSON)
+		case strings.HasSuffix(body.CSL, "ingestion mappings"):
+			columns = `{"ColumnName":"Name","DataType":"String"},{"ColumnName":"Kind","DataType":"String"},` +
+				`{"ColumnName":"Mapping","DataType":"String"},{"ColumnName":"LastUpdatedOn","DataType":"DateTime"},` +
+				`{"ColumnName":"Table","DataType":"String"},{"ColumnName":"Database","DataType":"String"}`
+			row = `["M","Json","[]","2021-01-01T00:00:00Z","T","test-db"]`
+		}
+		fmt.Fprintf(w, `{"Tables":[{"TableName":"Table_0","Columns":[%s],"Rows":[%s]}]}`, columns, row)
+	}))
+
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return reads
+	}
+}
+
+func TestKustoClient_DatabaseSchema(t *testing.T) {
+	server, reads := testSchemaServer(t)
+	defer server.Close()
+
+	client := testClient(t, &Config{Endpoint: server.URL}, context.Background())
+
+	schema, err := client.DatabaseSchema(context.Background(), "test-db")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if table := schema.Tables["T"]; table.Schema != "a:string,b:int" || table.Folder != "f" || table.DocString != "d" {
+		t.Fatalf("unexpected table: %+v", table)
+	}
+	if !schema.HasEntity("function", "F") || !schema.HasEntity("materialized-view", "MV") || schema.HasEntity("function", "MV") {
+		t.Fatalf("unexpected functions and materialized views: %+v, %+v", schema.Functions, schema.MaterializedViews)
+	}
+	if mapping := schema.Mapping("T", "json", "M"); mapping == nil || mapping.Mapping != "[]" {
+		t.Fatalf("unexpected mapping: %+v", mapping)
+	}
+	if mapping := schema.Mapping("T", "csv", "M"); mapping != nil {
+		t.Fatalf("expected no csv mapping, got: %+v", mapping)
+	}
+	if got := reads(); got != 1 {
+		t.Fatalf("expected the schema to be read once, got %d reads", got)
+	}
+}
+
+func TestKustoClient_DatabaseSchemaCached(t *testing.T) {
+	server, reads := testSchemaServer(t)
+	defer server.Close()
+
+	client := testClient(t, &Config{Endpoint: server.URL}, context.Background())
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.DatabaseSchema(context.Background(), "test-db"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if got := reads(); got != 1 {
+		t.Fatalf("expected the schema to be read once, got %d reads", got)
+	}
+
+	// Database names are case-insensitive
+	if _, err := client.DatabaseSchema(context.Background(), "TEST-DB"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := reads(); got != 1 {
+		t.Fatalf("expected the schema to be read once, got %d reads", got)
+	}
+
+	if err := client.Execute(context.Background(), "test-db", kusto.NewStmt(".create table T2 (a:string)")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := client.DatabaseSchema(context.Background(), "test-db"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := reads(); got != 2 {
+		t.Fatalf("expected the schema to be read again after a change, got %d reads", got)
+	}
+}
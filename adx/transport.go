@@ -0,0 +1,104 @@
+package adx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"unsafe"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// buildHTTPClient builds the HTTP client for the configured proxy, CA certificates and TLS settings. It returns
+// nil if none are set, in which case the defaults of net/http apply, including the HTTPS_PROXY and
+// NO_PROXY environment variables.
+func (c *Config) buildHTTPClient() (*http.Client, error) {
+	if c.ProxyURL == "" && c.CACertificatePath == "" && c.CACertificate == "" && c.TLSMinVersion == "" {
+		return nil, nil
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	transport.TLSClientConfig = &tls.Config{}
+
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("error parsing proxy_url %q: expected a URL such as http://proxy.contoso.com:3128", c.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if c.CACertificatePath != "" || c.CACertificate != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		var pem []byte
+		if c.CACertificatePath != "" {
+			if pem, err = ioutil.ReadFile(c.CACertificatePath); err != nil {
+				return nil, fmt.Errorf("error reading ca_certificate_path %q: %+v", c.CACertificatePath, err)
+			}
+		} else if pem, err = base64.StdEncoding.DecodeString(c.CACertificate); err != nil {
+			// The certificates may also be given as PEM directly
+			pem = []byte(c.CACertificate)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("error loading CA certificates: no PEM encoded certificates found")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if c.TLSMinVersion != "" {
+		version, ok := tlsVersions[c.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls_min_version %q", c.TLSMinVersion)
+		}
+		transport.TLSClientConfig.MinVersion = version
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// sender returns the HTTP client used to request tokens.
+func (c *Config) sender() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+	return http.DefaultClient
+}
+
+// setHTTPClient makes client send its requests with httpClient. The SDK creates a zero http.Client for each
+// kusto.Client and has no option to replace it, so the unexported field is set directly. This only affects the
+// provider's own kusto.Clients, and fails rather than silently ignoring the settings if the SDK's layout
+// changes.
+func setHTTPClient(client *kusto.Client, httpClient *http.Client) error {
+	conn := reflect.ValueOf(client).Elem().FieldByName("conn")
+	if conn.Kind() != reflect.Interface || conn.IsNil() || conn.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("error setting up the HTTP client: unsupported Kusto SDK")
+	}
+
+	field := conn.Elem().Elem().FieldByName("client")
+	if !field.IsValid() || field.Type() != reflect.TypeOf(httpClient) {
+		return fmt.Errorf("error setting up the HTTP client: unsupported Kusto SDK")
+	}
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(httpClient))
+
+	return nil
+}
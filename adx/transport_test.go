@@ -0,0 +1,105 @@
+package adx
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+)
+
+func testTransportMgmt(t *testing.T, config *Config) error {
+	meta, diags := config.Client(context.Background(), "test")
+	if diags.HasError() {
+		t.Fatalf("err: %+v", diags)
+	}
+	client, err := meta.Client(config.Endpoint)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.retry.MaxRetries = 0
+
+	resp, err := client.Mgmt(context.Background(), "test-db", kusto.NewStmt(".show tables"))
+	if err != nil {
+		return err
+	}
+	resp.Stop()
+	return nil
+}
+
+func TestConfig_caCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+	defer server.Close()
+
+	caCertificate := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	if err := testTransportMgmt(t, &Config{AuthMode: authModeNone, Endpoint: server.URL}); err == nil {
+		t.Fatalf("expected the self-signed certificate to be rejected without ca_certificate")
+	}
+	if err := testTransportMgmt(t, &Config{AuthMode: authModeNone, Endpoint: server.URL, CACertificate: caCertificate}); err != nil {
+		t.Fatalf("expected the certificate to be trusted with ca_certificate, got: %s", err)
+	}
+	if err := testTransportMgmt(t, &Config{AuthMode: authModeNone, Endpoint: server.URL, CACertificate: caCertificate, TLSMinVersion: "1.3"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestConfig_tlsMinVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	caCertificate := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	if err := testTransportMgmt(t, &Config{AuthMode: authModeNone, Endpoint: server.URL, CACertificate: caCertificate, TLSMinVersion: "1.3"}); err == nil {
+		t.Fatalf("expected TLS 1.2 to be rejected with tls_min_version 1.3")
+	}
+}
+
+func TestConfig_proxyURL(t *testing.T) {
+	defaultTransport := http.DefaultTransport
+
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprint(w, `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"Result","DataType":"String"}],"Rows":[]}]}`)
+	}))
+	defer proxy.Close()
+
+	if err := testTransportMgmt(t, &Config{AuthMode: authModeNone, Endpoint: "http://kusto.invalid:8080", ProxyURL: proxy.URL}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if proxied != "http://kusto.invalid:8080/v1/rest/mgmt" {
+		t.Fatalf("expected the request to be sent through the proxy, got %q", proxied)
+	}
+
+	if http.DefaultTransport != defaultTransport {
+		t.Fatalf("expected http.DefaultTransport to be left untouched")
+	}
+}
+
+func TestConfig_buildHTTPClient(t *testing.T) {
+	client, err := (&Config{}).buildHTTPClient()
+	if err != nil || client != nil {
+		t.Fatalf("expected no HTTP client without proxy or TLS settings, got %v (err: %v)", client, err)
+	}
+
+	for _, config := range []*Config{
+		{ProxyURL: "proxy.contoso.com"},
+		{CACertificate: "not a certificate"},
+		{CACertificatePath: "/nonexistent/ca.pem"},
+	} {
+		if _, err := config.buildHTTPClient(); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+}
@@ -2,9 +2,71 @@ package adx
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Resource IDs join their parts with "|". Each part is escaped, so that names containing "|" or "%", e.g.
+// bracket-quoted names, round-trip.
+var (
+	resourceIDEscaper   = strings.NewReplacer("%", "%25", "|", "%7C")
+	resourceIDUnescaper = strings.NewReplacer("%25", "%", "%7C", "|", "%7c", "|")
 )
 
+func formatResourceID(parts ...string) string {
+	escaped := make([]string, 0, len(parts))
+	for _, part := range parts {
+		escaped = append(escaped, resourceIDEscaper.Replace(part))
+	}
+	return strings.Join(escaped, "|")
+}
+
+// splitResourceID returns the unescaped parts of a resource ID, or nil if it doesn't have count parts.
+func splitResourceID(input string, count int) []string {
+	parts := strings.Split(input, "|")
+	if len(parts) != count {
+		return nil
+	}
+	for i, part := range parts {
+		parts[i] = resourceIDUnescaper.Replace(part)
+	}
+	return parts
+}
+
+// normalizeEndpoint returns the canonical form of a cluster endpoint, so that the same cluster always maps to
+// the same resource IDs and client: without trailing slashes and with a lowercase scheme and host.
+func normalizeEndpoint(endpoint string) string {
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// suppressEndpointDiff ignores differences between cluster_uri values which normalize to the same endpoint.
+func suppressEndpointDiff(_, old, new string, _ *schema.ResourceData) bool {
+	return normalizeEndpoint(old) == normalizeEndpoint(new)
+}
+
+// unquoteEntityName returns an entity name as Kusto reports it, without the `['…']` or `["…"]` quoting which
+// names with special characters need in commands.
+func unquoteEntityName(name string) string {
+	if len(name) < 4 || name[0] != '[' || name[len(name)-1] != ']' {
+		return name
+	}
+	quote := name[1]
+	if (quote != '\'' && quote != '"') || name[len(name)-2] != quote {
+		return name
+	}
+	return strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`).Replace(name[2 : len(name)-2])
+}
+
 type adxTableResource struct {
 	EndpointURI  string
 	Name string
@@ -20,29 +82,110 @@ type adxTableMappingResource struct {
 }
 
 func parseADXTableID(input string) (*adxTableResource, error) {
-	parts := strings.Split(input, "|")
-	if len(parts) != 3 {
+	parts := splitResourceID(input, 3)
+	if parts == nil {
 		return nil, fmt.Errorf("error parsing ADX Table resource ID: unexpected format: %q", input)
 	}
 
 	return &adxTableResource{
-		EndpointURI:  parts[0],
+		EndpointURI:  normalizeEndpoint(parts[0]),
 		DatabaseName: parts[1],
 		Name:    parts[2],
 	}, nil
 }
 
 func parseADXTableMappingID(input string) (*adxTableMappingResource, error) {
-	parts := strings.Split(input, "|")
-	if len(parts) != 5 {
-		return nil, fmt.Errorf("error parsing ADX Table resource ID: unexpected format: %q", input)
+	parts := splitResourceID(input, 5)
+	if parts == nil {
+		return nil, fmt.Errorf("error parsing ADX Table Mapping resource ID: unexpected format: %q", input)
 	}
 
 	return &adxTableMappingResource{
-		EndpointURI:  parts[0],
+		EndpointURI:  normalizeEndpoint(parts[0]),
 		DatabaseName: parts[1],
 		TableName:    parts[2],
 		Kind: parts[3],
 		Name:         parts[4],
 	}, nil
 }
+
+type adxTablePrincipalResource struct {
+	adxTableResource
+	Role string
+	FQN  string
+}
+
+func parseADXTablePrincipalID(input string) (*adxTablePrincipalResource, error) {
+	parts := splitResourceID(input, 5)
+	if parts == nil {
+		return nil, fmt.Errorf("error parsing ADX Table Principal resource ID: unexpected format: %q", input)
+	}
+
+	return &adxTablePrincipalResource{
+		adxTableResource: adxTableResource{
+			EndpointURI:  normalizeEndpoint(parts[0]),
+			DatabaseName: parts[1],
+			Name:         parts[2],
+		},
+		Role: parts[3],
+		FQN:  parts[4],
+	}, nil
+}
+
+type adxEntityPrincipalResource struct {
+	EndpointURI  string
+	DatabaseName string
+	EntityName   string
+	Role         string
+	FQN          string
+}
+
+func parseADXEntityPrincipalID(input string) (*adxEntityPrincipalResource, error) {
+	parts := splitResourceID(input, 5)
+	if parts == nil {
+		return nil, fmt.Errorf("error parsing ADX Principal resource ID: unexpected format: %q", input)
+	}
+
+	return &adxEntityPrincipalResource{
+		EndpointURI:  normalizeEndpoint(parts[0]),
+		DatabaseName: parts[1],
+		EntityName:   parts[2],
+		Role:         parts[3],
+		FQN:          parts[4],
+	}, nil
+}
+
+type adxClusterPrincipalResource struct {
+	EndpointURI string
+	Role        string
+	FQN         string
+}
+
+func parseADXClusterPrincipalID(input string) (*adxClusterPrincipalResource, error) {
+	parts := splitResourceID(input, 3)
+	if parts == nil {
+		return nil, fmt.Errorf("error parsing ADX Cluster Principal resource ID: unexpected format: %q", input)
+	}
+
+	return &adxClusterPrincipalResource{
+		EndpointURI: normalizeEndpoint(parts[0]),
+		Role:        parts[1],
+		FQN:         parts[2],
+	}, nil
+}
+
+func expandStringList(input []interface{}) []string {
+	result := make([]string, 0, len(input))
+	for _, v := range input {
+		result = append(result, v.(string))
+	}
+	return result
+}
+
+func expandStringMap(input map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(input))
+	for k, v := range input {
+		result[k] = v.(string)
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package adx
+
+import (
+	"testing"
+)
+
+func TestResourceID_roundTrip(t *testing.T) {
+	id := formatResourceID("https://test.kusto.windows.net", "test-db", "['a|b%7C']")
+	if expected := "https://test.kusto.windows.net|test-db|['a%7Cb%257C']"; id != expected {
+		t.Fatalf("expected ID %q, got %q", expected, id)
+	}
+
+	table, err := parseADXTableID(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if table.DatabaseName != "test-db" || table.Name != "['a|b%7C']" {
+		t.Fatalf("unexpected table: %+v", table)
+	}
+
+	if _, err := parseADXTableID("https://test.kusto.windows.net|test-db|a|b"); err == nil {
+		t.Fatalf("expected an error for an ID with an unescaped |")
+	}
+}
+
+func TestNormalizeEndpoint(t *testing.T) {
+	cases := map[string]string{
+		"https://test.kusto.windows.net":     "https://test.kusto.windows.net",
+		"https://test.kusto.windows.net/":    "https://test.kusto.windows.net",
+		" HTTPS://Test.Kusto.Windows.Net// ": "https://test.kusto.windows.net",
+		"http://localhost:8080/":             "http://localhost:8080",
+		"not a url":                          "not a url",
+	}
+	for input, expected := range cases {
+		if got := normalizeEndpoint(input); got != expected {
+			t.Errorf("normalizeEndpoint(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
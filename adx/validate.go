@@ -2,6 +2,7 @@ package adx
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/go-uuid"
@@ -84,3 +85,31 @@ func stringIsUUID(i interface{}, k cty.Path) diag.Diagnostics {
 
 	return nil
 }
+
+func stringIsDuration(i interface{}, k cty.Path) diag.Diagnostics {
+	v, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected type of %q to be string", k)
+	}
+
+	if _, err := time.ParseDuration(v); err != nil {
+		return diag.Errorf("expected %q to be a valid duration such as \"30s\", got %v", k, v)
+	}
+
+	return nil
+}
+
+func intAtLeast(min int) schema.SchemaValidateDiagFunc {
+	return func(i interface{}, k cty.Path) diag.Diagnostics {
+		v, ok := i.(int)
+		if !ok {
+			return diag.Errorf("expected type of %s to be int", k)
+		}
+
+		if v < min {
+			return diag.Errorf("expected %s to be at least (%d), got %d", k, min, v)
+		}
+
+		return nil
+	}
+}
@@ -4,8 +4,11 @@ go 1.16
 
 require (
 	github.com/Azure/azure-kusto-go v0.3.1
+	github.com/Azure/go-autorest/autorest v0.10.0
+	github.com/Azure/go-autorest/autorest/adal v0.8.2
 	github.com/Azure/go-autorest/autorest/azure/auth v0.4.2
 	github.com/hashicorp/go-cty v1.4.1-0.20200414143053-d3edf31b6320
 	github.com/hashicorp/go-uuid v1.0.1
 	github.com/hashicorp/terraform-plugin-sdk/v2 v2.4.4
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 )